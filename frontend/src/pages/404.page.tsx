@@ -0,0 +1,7 @@
+const NotFoundPage = ({ PageRoute }: { PageRoute: string }) => {
+  return <div>No page found at {PageRoute}</div>;
+};
+
+export const metadata = { title: "Page not found" };
+
+export default NotFoundPage;
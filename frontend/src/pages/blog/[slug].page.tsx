@@ -0,0 +1,5 @@
+const BlogPostPage = ({ Params }: { Params: { slug: string } }) => {
+  return <main>Blog post: {Params.slug}</main>;
+};
+
+export default BlogPostPage;
@@ -1,3 +1,8 @@
+export const metadata = {
+  title: "Home",
+  description: "The ikou example home page",
+};
+
 const HomePage = () => {
   return <main className="text-xl text-red-500">HomePage</main>;
 };
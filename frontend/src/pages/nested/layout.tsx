@@ -0,0 +1,5 @@
+import React from "react";
+
+export default function NestedLayout({ children }: { children?: React.ReactNode }) {
+  return <section className="p-4">{children}</section>;
+}
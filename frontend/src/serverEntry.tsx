@@ -1,5 +1,5 @@
 import * as React from "react";
-import { renderToString } from "react-dom/server";
+import { renderToReadableStream, renderToString } from "react-dom/server";
 import Root from "./root";
 import "./styles/base.css";
 
@@ -14,3 +14,11 @@ globalThis.renderApp = (PageComponent: React.FC<any>, props: any) => {
   );
   return renderedHTML;
 };
+
+// @ts-ignore
+globalThis.renderAppStream = (PageComponent: React.FC<any>, props: any) =>
+  renderToReadableStream(
+    <Root>
+      <PageComponent {...props} />
+    </Root>
+  );
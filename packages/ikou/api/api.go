@@ -0,0 +1,173 @@
+// Package api defines the handler contract for Ikou API routes. A route file
+// exports a Handler of type HandlerFunc:
+//
+//	package main
+//
+//	import (
+//		"net/http"
+//
+//		"github.com/bendigiorgio/ikou/api"
+//	)
+//
+//	func Handler(c *api.Context) error {
+//		var input struct{ Name string }
+//		if err := c.Bind(&input); err != nil {
+//			return err
+//		}
+//		return c.JSON(http.StatusOK, map[string]string{"id": c.Param("id"), "name": input.Name})
+//	}
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// MAX_BODY_SIZE is the largest request body Bind reads.
+const MAX_BODY_SIZE = 10 << 20
+
+// HandlerFunc handles an API request. A returned error is written as a JSON
+// error response, see Error.
+type HandlerFunc func(*Context) error
+
+// Context carries the request, its matched route params and helpers to read
+// the request and write the response.
+type Context struct {
+	Writer  http.ResponseWriter
+	Request *http.Request
+	// Route is the pattern of the matched route, e.g. /api/users/[id].
+	Route  string
+	Params map[string]string
+
+	query url.Values
+}
+
+// NewContext wraps a request for a HandlerFunc.
+func NewContext(w http.ResponseWriter, r *http.Request, route string, params map[string]string) *Context {
+	if params == nil {
+		params = map[string]string{}
+	}
+	return &Context{
+		Writer:  &responseWriter{ResponseWriter: w},
+		Request: r,
+		Route:   route,
+		Params:  params,
+	}
+}
+
+// Param returns the value of a dynamic route segment, e.g. id for
+// routes/api/users/[id]/get.go.
+func (c *Context) Param(name string) string {
+	return c.Params[name]
+}
+
+// Query returns the first value of a query string parameter.
+func (c *Context) Query(name string) string {
+	return c.QueryValues().Get(name)
+}
+
+// QueryValues returns every query string parameter.
+func (c *Context) QueryValues() url.Values {
+	if c.query == nil {
+		c.query = c.Request.URL.Query()
+	}
+	return c.query
+}
+
+// Bind decodes the JSON request body into v. Malformed or oversized bodies
+// return a 400 Error.
+func (c *Context) Bind(v interface{}) error {
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, MAX_BODY_SIZE)
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return &Error{Status: http.StatusBadRequest, Message: "invalid JSON body", Err: err}
+	}
+	return nil
+}
+
+// JSON writes v as a JSON response with the given status code.
+func (c *Context) JSON(status int, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	c.Writer.Header().Set("Content-Type", "application/json")
+	c.Writer.WriteHeader(status)
+	_, err = c.Writer.Write(body)
+	return err
+}
+
+// Text writes a plain text response with the given status code.
+func (c *Context) Text(status int, text string) error {
+	c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	c.Writer.WriteHeader(status)
+	_, err := c.Writer.Write([]byte(text))
+	return err
+}
+
+// NoContent writes an empty response with the given status code.
+func (c *Context) NoContent(status int) error {
+	c.Writer.WriteHeader(status)
+	return nil
+}
+
+// Error is an error with the status code and message to respond with. Errors
+// of any other type are answered with a 500 that does not expose them.
+type Error struct {
+	Status  int
+	Message string
+	Err     error
+}
+
+// NewError returns an Error responding with status and message.
+func NewError(status int, message string) *Error {
+	return &Error{Status: status, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %v", e.Message, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WriteError responds with err as {"error": message}, unless the handler has
+// already started the response.
+func (c *Context) WriteError(err error) {
+	if writer, ok := c.Writer.(*responseWriter); ok && writer.wroteHeader {
+		return
+	}
+
+	apiErr := &Error{Status: http.StatusInternalServerError, Message: http.StatusText(http.StatusInternalServerError)}
+	errors.As(err, &apiErr)
+
+	c.JSON(apiErr.Status, map[string]string{"error": apiErr.Message})
+}
+
+// responseWriter records whether the response has started.
+type responseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(body []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(body)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,133 @@
+package livereload
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+	"github.com/fsnotify/fsnotify"
+)
+
+// EVENTS_PATH is the server-sent events endpoint the dev client listens on.
+const EVENTS_PATH = "/_ikou/events"
+
+const (
+	// EventReload asks the browser to reload the current page.
+	EventReload = "reload"
+	// EventCSS asks the browser to refetch its stylesheets without reloading.
+	EventCSS = "css"
+)
+
+// ClientScript connects to EVENTS_PATH and reacts to the events sent by
+// Broadcast. It is injected into every page rendered by `ikou dev`.
+const ClientScript = `(function () {
+	var source = new EventSource("` + EVENTS_PATH + `");
+	source.addEventListener("` + EventReload + `", function () { window.location.reload(); });
+	source.addEventListener("` + EventCSS + `", function () {
+		document.querySelectorAll('link[rel="stylesheet"]').forEach(function (link) {
+			var url = new URL(link.href);
+			url.searchParams.set("t", Date.now());
+			link.href = url.toString();
+		});
+	});
+})();`
+
+var (
+	clientsMu sync.Mutex
+	clients   = map[chan string]struct{}{}
+)
+
+// Broadcast sends an event to every connected browser.
+func Broadcast(event string) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	for client := range clients {
+		select {
+		case client <- event:
+		default:
+			// The client is not keeping up, it will get the next event
+		}
+	}
+	utils.Logger.Sugar().Debugf("Sent %s event to %d clients", event, len(clients))
+}
+
+// Handler streams events to a single browser until it disconnects.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	client := make(chan string, 4)
+	clientsMu.Lock()
+	clients[client] = struct{}{}
+	clientsMu.Unlock()
+
+	defer func() {
+		clientsMu.Lock()
+		delete(clients, client)
+		clientsMu.Unlock()
+	}()
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event := <-client:
+			fmt.Fprintf(w, "event: %s\ndata: {}\n\n", event)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// WatchStylesheets sends EventCSS whenever a stylesheet in the static directory
+// changes, which is where the Tailwind output is written.
+func WatchStylesheets(staticPath string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		utils.Logger.Sugar().Errorf("Failed to create stylesheet watcher: %v", err)
+		return
+	}
+	defer watcher.Close()
+
+	err = watcher.Add(staticPath)
+	if err != nil {
+		utils.Logger.Sugar().Errorf("Failed to watch static directory: %v", err)
+		return
+	}
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if strings.EqualFold(filepath.Ext(event.Name), ".css") && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+				Broadcast(EventCSS)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			utils.Logger.Sugar().Errorf("Stylesheet watcher error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package react
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CLIENT_ASSET_PREFIX is the URL prefix content-hashed client scripts are
+// served under, and the directory they are written to by `ikou build`.
+const CLIENT_ASSET_PREFIX = "/_ikou/"
+
+// clientBuild describes the scripts a hydrated page needs: its own entry chunk
+// and the shared chunks it imports, which are preloaded. assets lists every
+// chunk the build stored, which are released when its bundle is evicted.
+type clientBuild struct {
+	script   string
+	preloads []string
+	assets   []string
+}
+
+// clientAsset is a built client chunk and the number of cached bundles whose
+// build produced it.
+type clientAsset struct {
+	contents []byte
+	refs     int
+}
+
+// clientAssets holds the client chunks of the cached bundles, keyed by file
+// name. Names contain a content hash and pages share chunks, so a chunk is only
+// dropped once no cached bundle references it.
+var (
+	clientAssetsMu sync.RWMutex
+	clientAssets   = map[string]*clientAsset{}
+)
+
+// retainClientAsset stores a chunk, or takes another reference to it when an
+// earlier build already produced the same file.
+func retainClientAsset(name string, contents []byte) {
+	clientAssetsMu.Lock()
+	defer clientAssetsMu.Unlock()
+
+	if asset, exists := clientAssets[name]; exists {
+		asset.refs++
+		return
+	}
+	clientAssets[name] = &clientAsset{contents: contents, refs: 1}
+}
+
+// releaseClientAssets drops a reference to each chunk, removing the chunks no
+// cached bundle uses any more.
+func releaseClientAssets(names []string) {
+	clientAssetsMu.Lock()
+	defer clientAssetsMu.Unlock()
+
+	for _, name := range names {
+		asset, exists := clientAssets[name]
+		if !exists {
+			continue
+		}
+		asset.refs--
+		if asset.refs <= 0 {
+			delete(clientAssets, name)
+		}
+	}
+}
+
+// ServeClientAsset serves a built client chunk with immutable cache headers.
+func ServeClientAsset(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, CLIENT_ASSET_PREFIX)
+
+	clientAssetsMu.RLock()
+	asset, exists := clientAssets[name]
+	clientAssetsMu.RUnlock()
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(asset.contents))
+}
+
+// WriteClientAssets writes the client chunks of the cached bundles into the
+// CLIENT_ASSET_PREFIX directory below outputDir.
+func WriteClientAssets(outputDir string) error {
+	assetDir := filepath.Join(outputDir, filepath.FromSlash(CLIENT_ASSET_PREFIX))
+	if err := os.MkdirAll(assetDir, fs.ModePerm); err != nil {
+		return fmt.Errorf("failed to create asset directory: %w", err)
+	}
+
+	clientAssetsMu.RLock()
+	defer clientAssetsMu.RUnlock()
+
+	for name, asset := range clientAssets {
+		assetPath := filepath.Join(assetDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(assetPath), fs.ModePerm); err != nil {
+			return fmt.Errorf("failed to write client assets: %w", err)
+		}
+		if err := os.WriteFile(assetPath, asset.contents, 0644); err != nil {
+			return fmt.Errorf("failed to write client assets: %w", err)
+		}
+	}
+	return nil
+}
+
+// storeClientOutputs resolves the page entry's script URL and the chunks it
+// imports from the esbuild metafile, then registers the chunks of the build.
+func storeClientOutputs(outputs map[string][]byte, metafile string, outdir string, entryOutput string) (*clientBuild, error) {
+	var meta struct {
+		Outputs map[string]struct {
+			Imports []struct {
+				Path string `json:"path"`
+				Kind string `json:"kind"`
+			} `json:"imports"`
+		} `json:"outputs"`
+	}
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse metafile: %w", err)
+	}
+
+	for outputPath, output := range meta.Outputs {
+		name := strings.TrimPrefix(filepath.ToSlash(outputPath), outdir+"/")
+		if !strings.HasPrefix(name, entryOutput+"-") || path.Ext(name) != ".js" {
+			continue
+		}
+
+		build := &clientBuild{script: CLIENT_ASSET_PREFIX + name}
+		for _, imported := range output.Imports {
+			if imported.Kind != "import-statement" {
+				continue
+			}
+			build.preloads = append(build.preloads, CLIENT_ASSET_PREFIX+strings.TrimPrefix(imported.Path, outdir+"/"))
+		}
+		for name, contents := range outputs {
+			retainClientAsset(name, contents)
+			build.assets = append(build.assets, name)
+		}
+		return build, nil
+	}
+
+	return nil, fmt.Errorf("no entry chunk %s in client build", entryOutput)
+}
+
+// clientEntryName turns a page path into a readable, URL-safe chunk name,
+// e.g. pages/blog/[slug].page.tsx becomes pages_blog_slug.
+func clientEntryName(pagePath string) string {
+	name := strings.TrimSuffix(filepath.ToSlash(pagePath), path.Ext(pagePath))
+	name = strings.TrimSuffix(name, ".page")
+
+	var builder strings.Builder
+	lastUnderscore := false
+	for _, char := range name {
+		isAlphanumeric := (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+		if isAlphanumeric {
+			builder.WriteRune(char)
+			lastUnderscore = false
+		} else if !lastUnderscore {
+			builder.WriteRune('_')
+			lastUnderscore = true
+		}
+	}
+	return strings.Trim(builder.String(), "_")
+}
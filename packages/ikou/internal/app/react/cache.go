@@ -0,0 +1,268 @@
+package react
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+)
+
+// bundleEntry holds the compiled bundles for a single page together with the
+// content hashes of every source file esbuild pulled into them.
+type bundleEntry struct {
+	ready  chan struct{}
+	err    error
+	server *serverBuild
+	client *clientBuild
+	inputs map[string]string
+	pool   *contextPool
+}
+
+var (
+	bundleCacheMu sync.Mutex
+	bundleCache   = map[string]*bundleEntry{}
+)
+
+// getBundles returns the server (and, when withClient is set, client) bundle for
+// the given page and its layouts, compiling it on the first request and serving it from memory
+// afterwards. Concurrent callers for the same page wait on a single build.
+//
+// In dev mode a cached entry is only reused while the content hashes of its
+// import graph still match the files on disk.
+func getBundles(serverEntry string, clientEntry string, pagePath string, layouts []string, basePath string, withClient bool) (*bundleEntry, error) {
+	key := bundleKey(pagePath, layouts, withClient)
+
+	for {
+		bundleCacheMu.Lock()
+		entry, exists := bundleCache[key]
+		if !exists {
+			entry = &bundleEntry{ready: make(chan struct{})}
+			bundleCache[key] = entry
+			bundleCacheMu.Unlock()
+
+			if utils.HasEmbeddedAssets() {
+				entry.loadPrebuilt(key, pagePath)
+			} else {
+				entry.build(serverEntry, clientEntry, pagePath, layouts, basePath, withClient)
+			}
+
+			if entry.err != nil {
+				bundleCacheMu.Lock()
+				if bundleCache[key] == entry {
+					delete(bundleCache, key)
+				}
+				bundleCacheMu.Unlock()
+			}
+			close(entry.ready)
+			return entry, entry.err
+		}
+		bundleCacheMu.Unlock()
+
+		<-entry.ready
+		if entry.err == nil && !(utils.IsDevMode() && entry.isStale()) {
+			return entry, nil
+		}
+
+		bundleCacheMu.Lock()
+		if bundleCache[key] == entry {
+			delete(bundleCache, key)
+			entry.evict()
+		}
+		bundleCacheMu.Unlock()
+	}
+}
+
+// bundleKey identifies the bundles of a page, its layouts and whether the
+// client bundle is included.
+func bundleKey(pagePath string, layouts []string, withClient bool) string {
+	key := strings.Join(append([]string{pagePath}, layouts...), "|")
+	if withClient {
+		key += "#client"
+	}
+	return key
+}
+
+func (e *bundleEntry) build(serverEntry string, clientEntry string, pagePath string, layouts []string, basePath string, withClient bool) {
+	inputs := []string{serverEntry}
+
+	server, serverInputs, err := buildBackend(serverEntry, pagePath, layouts, basePath)
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.server = server
+	inputs = append(inputs, serverInputs...)
+
+	pool, err := newContextPool(server.script, pagePath)
+	if err != nil {
+		e.err = newRenderError(err, server.sourceMap)
+		return
+	}
+	e.pool = pool
+
+	if withClient {
+		client, clientInputs, err := buildClient(clientEntry, pagePath, layouts, basePath)
+		if err != nil {
+			e.pool.close()
+			e.err = err
+			return
+		}
+		e.client = client
+		inputs = append(inputs, clientEntry)
+		inputs = append(inputs, clientInputs...)
+	}
+
+	e.inputs = hashInputs(inputs)
+}
+
+// ClosePage drops the cached bundles of a page and disposes of their render
+// contexts, keeping the client chunks for WriteClientAssets. The static site
+// generator calls it once a page has nothing left to render, so a build only
+// holds contexts for the pages in progress.
+func ClosePage(pagePath string, layouts []string) {
+	sources := resolvePageSources(pagePath, layouts)
+
+	bundleCacheMu.Lock()
+	defer bundleCacheMu.Unlock()
+
+	for _, withClient := range []bool{false, true} {
+		key := bundleKey(sources.pagePath, sources.layouts, withClient)
+		entry, exists := bundleCache[key]
+		if !exists {
+			continue
+		}
+		select {
+		case <-entry.ready:
+		default:
+			// Still building for a render that is not done yet
+			continue
+		}
+
+		delete(bundleCache, key)
+		if entry.pool != nil {
+			entry.pool.close()
+		}
+	}
+}
+
+// evict releases the V8 contexts held for the entry's server bundle and the
+// client chunks its build produced.
+func (e *bundleEntry) evict() {
+	if e.pool != nil {
+		e.pool.close()
+	}
+	if e.client != nil {
+		releaseClientAssets(e.client.assets)
+	}
+}
+
+// isStale reports whether any file in the entry's import graph has changed or
+// disappeared since the bundles were built.
+func (e *bundleEntry) isStale() bool {
+	for file, hash := range e.inputs {
+		current, err := hashFile(file)
+		if err != nil || current != hash {
+			return true
+		}
+	}
+	return false
+}
+
+// InvalidateBundles drops every cached bundle whose import graph contains the
+// changed file. An empty path clears the whole cache.
+func InvalidateBundles(changedPath string) {
+	bundleCacheMu.Lock()
+	defer bundleCacheMu.Unlock()
+
+	if changedPath == "" {
+		for key, entry := range bundleCache {
+			go func(entry *bundleEntry) {
+				<-entry.ready
+				entry.evict()
+			}(entry)
+			delete(bundleCache, key)
+		}
+		return
+	}
+
+	absPath, err := filepath.Abs(changedPath)
+	if err != nil {
+		absPath = changedPath
+	}
+
+	for key, entry := range bundleCache {
+		select {
+		case <-entry.ready:
+		default:
+			// Still building, the dev staleness check will catch it
+			continue
+		}
+		if _, exists := entry.inputs[absPath]; exists {
+			delete(bundleCache, key)
+			entry.evict()
+			utils.Logger.Sugar().Debugf("Invalidated bundle cache for %s", key)
+		}
+	}
+}
+
+// metafileInputs extracts the source files from an esbuild metafile, skipping
+// node_modules and the temporary entry files written by the build functions.
+func metafileInputs(metafile string) ([]string, error) {
+	var meta struct {
+		Inputs map[string]json.RawMessage `json:"inputs"`
+	}
+	if err := json.Unmarshal([]byte(metafile), &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse metafile: %w", err)
+	}
+
+	inputs := make([]string, 0, len(meta.Inputs))
+	for input := range meta.Inputs {
+		if strings.Contains(input, "node_modules/") || isTempEntry(input) {
+			continue
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+// isTempEntry reports whether the file is one of the temporary entry files
+// written by buildBackend and buildClient.
+func isTempEntry(file string) bool {
+	for _, pattern := range []string{serverEntryTempPattern, clientEntryTempPattern} {
+		if matched, _ := filepath.Match(pattern, filepath.Base(file)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func hashInputs(files []string) map[string]string {
+	hashes := make(map[string]string, len(files))
+	for _, file := range files {
+		absPath, err := filepath.Abs(file)
+		if err != nil {
+			continue
+		}
+		hash, err := hashFile(absPath)
+		if err != nil {
+			continue
+		}
+		hashes[absPath] = hash
+	}
+	return hashes
+}
+
+func hashFile(file string) (string, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
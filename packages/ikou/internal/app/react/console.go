@@ -0,0 +1,80 @@
+package react
+
+import (
+	"strings"
+
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	v8 "rogchap.com/v8go"
+)
+
+// consoleLevels maps the console methods available during server rendering to
+// the level their output is logged at.
+var consoleLevels = map[string]zapcore.Level{
+	"debug": zapcore.DebugLevel,
+	"log":   zapcore.InfoLevel,
+	"info":  zapcore.InfoLevel,
+	"warn":  zapcore.WarnLevel,
+	"error": zapcore.ErrorLevel,
+	"trace": zapcore.DebugLevel,
+}
+
+// installConsole replaces V8's built-in console, which discards its output, with
+// one whose methods write to utils.Logger, tagged with the page and the request
+// being rendered.
+func (rc *renderContext) installConsole() error {
+	console := v8.NewObjectTemplate(rc.iso)
+	for method, level := range consoleLevels {
+		level := level
+		callback := v8.NewFunctionTemplate(rc.iso, func(info *v8.FunctionCallbackInfo) *v8.Value {
+			rc.logConsole(level, info.Context(), info.Args())
+			return nil
+		})
+		if err := console.Set(method, callback); err != nil {
+			return err
+		}
+	}
+
+	instance, err := console.NewInstance(rc.ctx)
+	if err != nil {
+		return err
+	}
+	return rc.ctx.Global().Set("console", instance)
+}
+
+func (rc *renderContext) logConsole(level zapcore.Level, ctx *v8.Context, args []*v8.Value) {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = formatConsoleArg(ctx, arg)
+	}
+
+	utils.Logger.Log(level, strings.Join(parts, " "), rc.logFields()...)
+}
+
+// logFields identifies the page and the request being rendered in log entries.
+func (rc *renderContext) logFields() []zap.Field {
+	fields := []zap.Field{zap.String("page", rc.pagePath)}
+	if rc.requestID != "" {
+		fields = append(fields, zap.String("requestId", rc.requestID))
+	}
+	return fields
+}
+
+// formatConsoleArg prints errors with their stack and plain objects as JSON,
+// falling back to the value's string conversion.
+func formatConsoleArg(ctx *v8.Context, arg *v8.Value) string {
+	switch {
+	case arg.IsString():
+		return arg.String()
+	case arg.IsNativeError():
+		if stack, err := arg.Object().Get("stack"); err == nil && stack.IsString() {
+			return stack.String()
+		}
+	case arg.IsObject() && !arg.IsFunction():
+		if json, err := v8.JSONStringify(ctx, arg); err == nil {
+			return json
+		}
+	}
+	return arg.String()
+}
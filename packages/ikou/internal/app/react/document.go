@@ -0,0 +1,126 @@
+package react
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+)
+
+// DOCUMENT_TEMPLATE is the optional Go template in the frontend base path that
+// replaces the default document shell. It is executed with PageData and can use
+// the following slots:
+//
+//   - {{.HeadTags}}: title, meta tags, the stylesheet and module preloads
+//   - {{.Body}}: the server rendered page inside <div id="app">
+//   - {{.PropsScript}}: the script setting window.APP_PROPS for hydration
+//   - {{.Scripts}}: the page's client script and, in dev, the live reload client
+//
+// The raw values (.Metadata, .RenderedContent, .InitialProps) are available too.
+const DOCUMENT_TEMPLATE = "document.html"
+
+// slotTemplates holds the fragments behind the PageData slot methods.
+const slotTemplates = `
+{{define "head"}}<title>{{if .Metadata.Title}}{{.Metadata.Title}}{{else}}React App{{end}}</title>
+	{{with .Metadata}}{{if .Description}}<meta name="description" content="{{.Description}}">
+	{{end}}{{if .Canonical}}<link rel="canonical" href="{{.Canonical}}">
+	{{end}}{{range $name, $content := .Meta}}<meta name="{{$name}}" content="{{$content}}">
+	{{end}}{{range $property, $content := .OpenGraph}}<meta property="og:{{$property}}" content="{{$content}}">
+	{{end}}{{end}}<link href="/public/style.css" rel="stylesheet">
+	{{range .Preloads}}<link rel="modulepreload" href="{{.}}">
+	{{end}}{{end}}
+{{define "body"}}<div id="app">{{.RenderedContent}}</div>{{end}}
+{{define "props"}}<script id="IKOU_PROPS">window.APP_PROPS = {{.InitialProps}};</script>{{end}}
+{{define "scripts"}}{{if .Script}}<script type="module" src="{{.Script}}"></script>
+	{{end}}{{if .DevScript}}<script>{{.DevScript}}</script>{{end}}{{end}}
+`
+
+var slots = template.Must(template.New("slots").Parse(slotTemplates))
+
+var (
+	documentTemplateMu sync.Mutex
+	documentTemplate   *template.Template
+)
+
+func (p PageData) executeSlot(name string) template.HTML {
+	var buf bytes.Buffer
+	if err := slots.ExecuteTemplate(&buf, name, p); err != nil {
+		utils.Logger.Sugar().Errorf("Error rendering %s slot: %v", name, err)
+		return ""
+	}
+	return template.HTML(buf.String())
+}
+
+// HeadTags renders the tags that belong in the document <head>.
+func (p PageData) HeadTags() template.HTML {
+	return p.executeSlot("head")
+}
+
+// Body renders the server rendered page inside its hydration root.
+func (p PageData) Body() template.HTML {
+	return p.executeSlot("body")
+}
+
+// PropsScript renders the script exposing the page props to the client.
+func (p PageData) PropsScript() template.HTML {
+	return p.executeSlot("props")
+}
+
+// Scripts renders the client script tags of the page.
+func (p PageData) Scripts() template.HTML {
+	return p.executeSlot("scripts")
+}
+
+// loadDocumentTemplate returns the document template, parsed on the first
+// render and reused afterwards. In dev mode the file is read on every render
+// so edits show up without a restart.
+func loadDocumentTemplate() (*template.Template, error) {
+	if utils.IsDevMode() {
+		return parseDocumentTemplate()
+	}
+
+	documentTemplateMu.Lock()
+	defer documentTemplateMu.Unlock()
+
+	if documentTemplate == nil {
+		tmpl, err := parseDocumentTemplate()
+		if err != nil {
+			return nil, err
+		}
+		documentTemplate = tmpl
+	}
+	return documentTemplate, nil
+}
+
+// parseDocumentTemplate parses DOCUMENT_TEMPLATE from the frontend base path,
+// or from the embedded assets of a server binary, falling back to
+// ssrHtmlTemplate when the file does not exist.
+func parseDocumentTemplate() (*template.Template, error) {
+	documentPath := path.Join(utils.GlobalConfig.BasePath, DOCUMENT_TEMPLATE)
+
+	var content []byte
+	var err error
+	if utils.HasEmbeddedAssets() {
+		documentPath = DOCUMENT_TEMPLATE
+		content, err = fs.ReadFile(utils.EmbeddedAssets, DOCUMENT_TEMPLATE)
+	} else {
+		content, err = os.ReadFile(documentPath)
+	}
+	if os.IsNotExist(err) {
+		return template.New("ssrPage").Parse(ssrHtmlTemplate)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", documentPath, err)
+	}
+
+	tmpl, err := template.New(DOCUMENT_TEMPLATE).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", documentPath, err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,149 @@
+package react
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/bendigiorgio/ikou/internal/app/livereload"
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+	esbuild "github.com/evanw/esbuild/pkg/api"
+	v8 "rogchap.com/v8go"
+)
+
+// BuildError is returned when esbuild fails to bundle a page.
+type BuildError struct {
+	Target   string
+	Messages []esbuild.Message
+}
+
+func (e *BuildError) Error() string {
+	if len(e.Messages) == 0 {
+		return fmt.Sprintf("%s build failed", e.Target)
+	}
+
+	message := e.Messages[0]
+	if message.Location == nil {
+		return fmt.Sprintf("%s build failed: %s", e.Target, message.Text)
+	}
+	return fmt.Sprintf("%s build failed: %s:%d:%d: %s", e.Target, message.Location.File, message.Location.Line, message.Location.Column, message.Text)
+}
+
+// RenderError is returned when the server bundle throws while being evaluated
+// or rendered. Location and Stack point at the original sources when a source
+// map is available.
+type RenderError struct {
+	Message  string
+	Location string
+	Stack    string
+}
+
+func (e *RenderError) Error() string {
+	if e.Location == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (%s)", e.Message, e.Location)
+}
+
+// newRenderError converts a V8 exception into a RenderError, resolving bundle
+// positions through the source map.
+func newRenderError(err error, sm *sourceMap) error {
+	var jsErr *v8.JSError
+	if !errors.As(err, &jsErr) {
+		return err
+	}
+
+	return &RenderError{
+		Message:  jsErr.Message,
+		Location: sm.rewriteStack(jsErr.Location),
+		Stack:    sm.rewriteStack(jsErr.StackTrace),
+	}
+}
+
+const errorOverlayTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>{{.Title}}</title>
+    <style>
+        body { margin: 0; padding: 32px; background: #1a1a1a; color: #e8e8e8; font-family: ui-monospace, Menlo, monospace; }
+        h1 { color: #ff6b6b; font-size: 20px; margin: 0 0 24px; }
+        .error { background: #262626; border-left: 4px solid #ff6b6b; padding: 16px; margin-bottom: 16px; }
+        .message { font-weight: bold; white-space: pre-wrap; }
+        .location { color: #9ca3af; margin-top: 8px; }
+        pre { margin: 12px 0 0; color: #d4d4d4; white-space: pre-wrap; }
+        .caret { color: #ff6b6b; }
+        footer { color: #6b7280; font-size: 12px; }
+    </style>
+</head>
+<body>
+    <h1>{{.Title}}</h1>
+    {{range .Errors}}
+    <div class="error">
+        <div class="message">{{.Message}}</div>
+        {{if .Location}}<div class="location">{{.Location}}</div>{{end}}
+        {{if .Snippet}}<pre>{{.Snippet}}
+<span class="caret">{{.Caret}}</span></pre>{{end}}
+        {{if .Stack}}<pre>{{.Stack}}</pre>{{end}}
+    </div>
+    {{end}}
+    <footer>The page reloads once the error is fixed and the file is saved.</footer>
+    <script>{{.DevScript}}</script>
+</body>
+</html>
+`
+
+var errorOverlay = template.Must(template.New("errorOverlay").Parse(errorOverlayTemplate))
+
+type overlayError struct {
+	Message  string
+	Location string
+	Snippet  string
+	Caret    string
+	Stack    string
+}
+
+// WriteErrorOverlay responds with an HTML page describing a build or render
+// failure. It is meant for `ikou dev` only as it exposes source code.
+func WriteErrorOverlay(w http.ResponseWriter, err error) {
+	title := "Server Error"
+	var details []overlayError
+
+	var buildErr *BuildError
+	var renderErr *RenderError
+	switch {
+	case errors.As(err, &buildErr):
+		title = "Build Error"
+		for _, message := range buildErr.Messages {
+			detail := overlayError{Message: message.Text}
+			if message.Location != nil {
+				detail.Location = fmt.Sprintf("%s:%d:%d", message.Location.File, message.Location.Line, message.Location.Column)
+				detail.Snippet = message.Location.LineText
+				detail.Caret = strings.Repeat(" ", message.Location.Column) + "^"
+			}
+			details = append(details, detail)
+		}
+	case errors.As(err, &renderErr):
+		title = "Render Error"
+		details = append(details, overlayError{
+			Message:  renderErr.Message,
+			Location: renderErr.Location,
+			Stack:    renderErr.Stack,
+		})
+	default:
+		details = append(details, overlayError{Message: err.Error()})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	if err := errorOverlay.Execute(w, struct {
+		Title     string
+		Errors    []overlayError
+		DevScript template.JS
+	}{title, details, template.JS(livereload.ClientScript)}); err != nil {
+		utils.Logger.Sugar().Errorf("Error writing error overlay: %v", err)
+	}
+}
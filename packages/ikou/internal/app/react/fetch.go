@@ -0,0 +1,198 @@
+package react
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+	v8 "rogchap.com/v8go"
+)
+
+// FETCH_TIMEOUT bounds every fetch made during server rendering.
+const FETCH_TIMEOUT = 10 * time.Second
+
+const defaultFetchMaxBodyMB = 10
+
+// fetchRequest is what the fetch polyfill passes to Go, as JSON.
+type fetchRequest struct {
+	URL     string      `json:"url"`
+	Method  string      `json:"method"`
+	Headers [][2]string `json:"headers"`
+	Body    string      `json:"body"`
+}
+
+// fetchResult is what fetch resolves with, as JSON.
+type fetchResult struct {
+	Status     int         `json:"status"`
+	StatusText string      `json:"statusText"`
+	URL        string      `json:"url"`
+	Headers    [][2]string `json:"headers"`
+	Body       string      `json:"body"`
+}
+
+var fetchClient = &http.Client{
+	Timeout: FETCH_TIMEOUT,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		return checkFetchAllowed(req.URL)
+	},
+}
+
+// checkFetchAllowed reports an error unless the host is the app's own baseUrl
+// host or matches an entry of ssr.fetchAllowlist. Entries are host names, with
+// an optional port, and may start with "*." to allow the host and every
+// subdomain of it. Any other "*" is matched literally.
+func checkFetchAllowed(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", target.Scheme)
+	}
+
+	if base, err := url.Parse(utils.GlobalConfig.BaseUrl); err == nil && base.Host == target.Host {
+		return nil
+	}
+
+	for _, allowed := range utils.GlobalConfig.SSR.FetchAllowlist {
+		host := target.Host
+		if !strings.Contains(allowed, ":") {
+			host = target.Hostname()
+		}
+		if host == allowed {
+			return nil
+		}
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok && (host == suffix || strings.HasSuffix(host, "."+suffix)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s is not in ssr.fetchAllowlist", target.Host)
+}
+
+// fetch implements __ikouFetch. It returns a promise that resolves with the
+// fetchResult JSON, or rejects with an error message, once the request made on
+// another goroutine completes.
+func (rc *renderContext) fetch(info *v8.FunctionCallbackInfo) *v8.Value {
+	ctx := info.Context()
+	resolver, err := v8.NewPromiseResolver(ctx)
+	if err != nil {
+		return nil
+	}
+	reject := func(err error) {
+		message, _ := v8.NewValue(rc.iso, err.Error())
+		resolver.Reject(message)
+	}
+
+	args := info.Args()
+	if len(args) == 0 {
+		reject(fmt.Errorf("missing request"))
+		return resolver.GetPromise().Value
+	}
+
+	req, err := newFetchRequest(args[0].String())
+	if err != nil {
+		reject(err)
+		return resolver.GetPromise().Value
+	}
+
+	rc.loop.goAsync(func() func() {
+		result, err := doFetch(req)
+		return func() {
+			if err != nil {
+				reject(err)
+				return
+			}
+			encoded, _ := json.Marshal(result)
+			value, _ := v8.NewValue(rc.iso, string(encoded))
+			resolver.Resolve(value)
+		}
+	})
+
+	return resolver.GetPromise().Value
+}
+
+// newFetchRequest builds the HTTP request for a fetch call. Relative URLs are
+// resolved against the configured baseUrl.
+func newFetchRequest(encoded string) (*http.Request, error) {
+	var request fetchRequest
+	if err := json.Unmarshal([]byte(encoded), &request); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+
+	target, err := url.Parse(request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", request.URL, err)
+	}
+	if !target.IsAbs() {
+		base, err := url.Parse(utils.GlobalConfig.BaseUrl)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve %s without a valid baseUrl: %w", request.URL, err)
+		}
+		target = base.ResolveReference(target)
+	}
+	if err := checkFetchAllowed(target); err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if request.Body != "" {
+		body = strings.NewReader(request.Body)
+	}
+	req, err := http.NewRequest(request.Method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range request.Headers {
+		req.Header.Add(header[0], header[1])
+	}
+	return req, nil
+}
+
+// fetchMaxBodyBytes is the largest response body a fetch reads, from
+// ssr.fetchMaxBodyMb.
+func fetchMaxBodyBytes() int64 {
+	if limit := utils.GlobalConfig.SSR.FetchMaxBodyMB; limit > 0 {
+		return int64(limit) << 20
+	}
+	return defaultFetchMaxBodyMB << 20
+}
+
+func doFetch(req *http.Request) (*fetchResult, error) {
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read one byte past the limit to tell a body of exactly the limit from a
+	// larger one without a Content-Length
+	limit := fetchMaxBodyBytes()
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("response from %s exceeds ssr.fetchMaxBodyMb", resp.Request.URL.Host)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("response from %s exceeds ssr.fetchMaxBodyMb", resp.Request.URL.Host)
+	}
+
+	result := &fetchResult{
+		Status:     resp.StatusCode,
+		StatusText: strings.TrimPrefix(resp.Status, fmt.Sprintf("%d ", resp.StatusCode)),
+		URL:        resp.Request.URL.String(),
+		Body:       string(body),
+	}
+	for name, values := range resp.Header {
+		for _, value := range values {
+			result.Headers = append(result.Headers, [2]string{strings.ToLower(name), value})
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,46 @@
+package react
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+)
+
+func TestCheckFetchAllowed(t *testing.T) {
+	previous := utils.GlobalConfig
+	t.Cleanup(func() { utils.GlobalConfig = previous })
+	utils.GlobalConfig.BaseUrl = "https://app.example.com"
+	utils.GlobalConfig.SSR.FetchAllowlist = []string{"api.example.com", "*.cdn.example.com", "*example.org", "localhost:9000"}
+
+	tests := []struct {
+		target  string
+		allowed bool
+	}{
+		{target: "https://app.example.com/data", allowed: true},
+		{target: "https://api.example.com/users", allowed: true},
+		{target: "https://api.example.com:8443/users", allowed: true},
+		{target: "https://evilapi.example.com", allowed: false},
+		{target: "https://cdn.example.com/a.js", allowed: true},
+		{target: "https://img.cdn.example.com/a.png", allowed: true},
+		{target: "https://evilcdn.example.com/a.js", allowed: false},
+		{target: "https://cdn.example.com.evil.com", allowed: false},
+		{target: "https://evilexample.org", allowed: false},
+		{target: "https://www.example.org", allowed: false},
+		{target: "http://localhost:9000/api", allowed: true},
+		{target: "http://localhost:9001/api", allowed: false},
+		{target: "file:///etc/passwd", allowed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			target, err := url.Parse(test.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := checkFetchAllowed(target); (err == nil) != test.allowed {
+				t.Errorf("checkFetchAllowed(%s) = %v, want allowed %v", test.target, err, test.allowed)
+			}
+		})
+	}
+}
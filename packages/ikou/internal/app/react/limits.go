@@ -0,0 +1,94 @@
+package react
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+)
+
+const (
+	defaultRenderTimeout = 5 * time.Second
+	defaultMaxHeapMB     = 256
+)
+
+// LimitError is returned when a render is terminated for running longer than
+// ssr.timeoutMs or for being found over ssr.maxHeapMb. The heap is only
+// measured between scripts, see checkHeap, so it is not a hard cap.
+type LimitError struct {
+	Limit string
+	Value string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("render exceeded the %s limit of %s", e.Limit, e.Value)
+}
+
+func renderTimeout() time.Duration {
+	if timeout := utils.GlobalConfig.SSR.TimeoutMs; timeout > 0 {
+		return time.Duration(timeout) * time.Millisecond
+	}
+	return defaultRenderTimeout
+}
+
+// maxHeapBytes is the heap size a render context may be found using between
+// scripts. v8go cannot set V8's own heap limit, so a single script can still
+// allocate past it until the render timeout stops it.
+func maxHeapBytes() uint64 {
+	if limit := utils.GlobalConfig.SSR.MaxHeapMB; limit > 0 {
+		return uint64(limit) << 20
+	}
+	return defaultMaxHeapMB << 20
+}
+
+// exceedsHeap reports whether the isolate uses more heap than ssr.maxHeapMb.
+// V8 does not allow reading heap statistics while another thread runs
+// JavaScript in the isolate, so it must only be called on the render goroutine
+// between scripts.
+func (rc *renderContext) exceedsHeap() bool {
+	return rc.iso.GetHeapStatistics().UsedHeapSize > maxHeapBytes()
+}
+
+// checkHeap returns the heap LimitError if the isolate is over ssr.maxHeapMb.
+// It is checked after evaluating the bundle and between the tasks of the event
+// loop, a single script cannot be stopped for its heap while it runs.
+func (rc *renderContext) checkHeap() *LimitError {
+	if !rc.exceedsHeap() {
+		return nil
+	}
+	return &LimitError{Limit: "heap", Value: fmt.Sprintf("%dMB", maxHeapBytes()>>20)}
+}
+
+// watch terminates the JavaScript running in the context once it passes the
+// render timeout and interrupts the event loop if it is waiting. The returned
+// function stops watching and reports whether the limit was hit, in which case
+// the context must be discarded.
+func (rc *renderContext) watch() func() *LimitError {
+	done := make(chan struct{})
+	result := make(chan *LimitError, 1)
+	interrupt := rc.loop.interrupt
+
+	go func() {
+		timeout := renderTimeout()
+		deadline := time.NewTimer(timeout)
+		defer deadline.Stop()
+
+		select {
+		case <-done:
+			result <- nil
+			return
+		case <-deadline.C:
+		}
+
+		// TerminateExecution is the only isolate method safe to call from
+		// another goroutine
+		rc.iso.TerminateExecution()
+		close(interrupt)
+		result <- &LimitError{Limit: "time", Value: timeout.String()}
+	}()
+
+	return func() *LimitError {
+		close(done)
+		return <-result
+	}
+}
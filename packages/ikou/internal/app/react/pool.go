@@ -0,0 +1,316 @@
+package react
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+	"go.uber.org/zap"
+	v8 "rogchap.com/v8go"
+)
+
+const defaultPoolSize = 4
+
+// POOL_STATS_INTERVAL is how often the server logs the pool wait metrics.
+const POOL_STATS_INTERVAL = time.Minute
+
+var errPoolClosed = errors.New("render context pool closed")
+
+// skipWarming stops new pools from filling up in the background, see
+// DisablePoolWarming.
+var skipWarming atomic.Bool
+
+// DisablePoolWarming makes new pools create contexts only when renders need
+// them. The static site generator renders each page a few times at most, so
+// warming would only fill every pool with isolates that are never used.
+func DisablePoolWarming() {
+	skipWarming.Store(true)
+}
+
+// renderContext is a V8 isolate and context that already evaluated a page's
+// server bundle and is ready to render.
+type renderContext struct {
+	iso      *v8.Isolate
+	ctx      *v8.Context
+	pagePath string
+
+	// requestID identifies the request being rendered in console output.
+	requestID string
+	// write receives the chunks of a streaming render, see stream.go.
+	write func(chunk []byte)
+	loop  *eventLoop
+}
+
+// contextPool hands out pre-warmed render contexts for a single server bundle.
+// At most size contexts exist at once; callers block until one is released or
+// discarded.
+type contextPool struct {
+	bundle   string
+	pagePath string
+	size     int
+	idle     chan *renderContext
+	// slots holds a token for every context that exists, so discarding one
+	// lets a waiting caller create its replacement.
+	slots  chan struct{}
+	done   chan struct{}
+	mu     sync.Mutex
+	closed bool
+}
+
+// PoolStats is a snapshot of how long renders waited for a free context.
+type PoolStats struct {
+	Acquired  int64
+	Waited    int64
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+var (
+	poolAcquired  atomic.Int64
+	poolWaited    atomic.Int64
+	poolTotalWait atomic.Int64
+	poolMaxWait   atomic.Int64
+)
+
+// GetPoolStats returns the render context pool wait metrics accumulated since
+// the process started.
+func GetPoolStats() PoolStats {
+	return PoolStats{
+		Acquired:  poolAcquired.Load(),
+		Waited:    poolWaited.Load(),
+		TotalWait: time.Duration(poolTotalWait.Load()),
+		MaxWait:   time.Duration(poolMaxWait.Load()),
+	}
+}
+
+// ReportPoolStats logs the pool wait metrics of every interval in which pages
+// were rendered, so ssr.poolSize can be sized under load. It never returns.
+func ReportPoolStats(interval time.Duration) {
+	var last PoolStats
+	for range time.Tick(interval) {
+		stats := GetPoolStats()
+		if stats.Acquired == last.Acquired {
+			continue
+		}
+
+		utils.Logger.Info(
+			"Render context pool",
+			zap.Int64("acquired", stats.Acquired-last.Acquired),
+			zap.Int64("waited", stats.Waited-last.Waited),
+			zap.Duration("totalWait", stats.TotalWait-last.TotalWait),
+			zap.Duration("maxWait", stats.MaxWait),
+			zap.Int("poolSize", poolSize()),
+		)
+		last = stats
+	}
+}
+
+func recordPoolWait(wait time.Duration) {
+	poolAcquired.Add(1)
+	if wait <= 0 {
+		return
+	}
+	poolWaited.Add(1)
+	poolTotalWait.Add(int64(wait))
+	for {
+		current := poolMaxWait.Load()
+		if int64(wait) <= current || poolMaxWait.CompareAndSwap(current, int64(wait)) {
+			break
+		}
+	}
+}
+
+func poolSize() int {
+	if size := utils.GlobalConfig.SSR.PoolSize; size > 0 {
+		return size
+	}
+	return defaultPoolSize
+}
+
+func newContextPool(bundle string, pagePath string) (*contextPool, error) {
+	size := poolSize()
+
+	pool := &contextPool{
+		bundle:   bundle,
+		pagePath: pagePath,
+		size:     size,
+		idle:     make(chan *renderContext, size),
+		slots:    make(chan struct{}, size),
+		done:     make(chan struct{}),
+	}
+
+	// Warm a single context up front so the first request does not pay for it
+	rc, err := newRenderContext(bundle, pagePath)
+	if err != nil {
+		return nil, err
+	}
+	pool.slots <- struct{}{}
+	pool.idle <- rc
+
+	if !skipWarming.Load() {
+		go pool.warm()
+	}
+	return pool, nil
+}
+
+// warm fills the rest of the pool in the background, stopping early once
+// renders have taken the free slots themselves.
+func (p *contextPool) warm() {
+	for i := 1; i < p.size; i++ {
+		select {
+		case p.slots <- struct{}{}:
+		case <-p.done:
+			return
+		default:
+			return
+		}
+
+		rc, err := p.create()
+		if err != nil {
+			utils.Logger.Sugar().Warnf("Failed to warm render context for %s: %v", p.pagePath, err)
+			return
+		}
+		p.release(rc)
+	}
+}
+
+func newRenderContext(bundle string, pagePath string) (*renderContext, error) {
+	iso := v8.NewIsolate()
+	rc := &renderContext{iso: iso, pagePath: pagePath, loop: newEventLoop()}
+
+	global := v8.NewObjectTemplate(iso)
+	if err := rc.installRuntime(global); err != nil {
+		iso.Dispose()
+		return nil, fmt.Errorf("failed to install runtime: %w", err)
+	}
+	if err := global.Set(STREAM_WRITE_FN, v8.NewFunctionTemplate(iso, rc.writeCallback)); err != nil {
+		iso.Dispose()
+		return nil, fmt.Errorf("failed to install %s: %w", STREAM_WRITE_FN, err)
+	}
+
+	rc.ctx = v8.NewContext(iso, global)
+	if err := rc.installConsole(); err != nil {
+		rc.dispose()
+		return nil, fmt.Errorf("failed to install console: %w", err)
+	}
+	if _, err := rc.ctx.RunScript(processScript()+runtimeScript, "runtime.js"); err != nil {
+		rc.dispose()
+		return nil, fmt.Errorf("failed to evaluate runtime: %w", err)
+	}
+	stopWatch := rc.watch()
+	_, err := rc.ctx.RunScript(bundle, "bundle.js")
+	if limitErr := stopWatch(); limitErr != nil {
+		err = limitErr
+	} else if limitErr := rc.checkHeap(); err == nil && limitErr != nil {
+		err = limitErr
+	}
+	if err != nil {
+		rc.dispose()
+		return nil, fmt.Errorf("failed to evaluate server bundle: %w", err)
+	}
+
+	return rc, nil
+}
+
+func (rc *renderContext) dispose() {
+	rc.loop.reset()
+	rc.ctx.Close()
+	rc.iso.Dispose()
+}
+
+// acquire returns an idle context, creates a new one while the pool is below
+// its size, or waits for another render to release or discard one.
+func (p *contextPool) acquire() (*renderContext, error) {
+	select {
+	case rc := <-p.idle:
+		recordPoolWait(0)
+		return rc, nil
+	case <-p.done:
+		return nil, errPoolClosed
+	default:
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		recordPoolWait(0)
+		return p.create()
+	default:
+	}
+
+	start := time.Now()
+	select {
+	case rc := <-p.idle:
+		wait := time.Since(start)
+		recordPoolWait(wait)
+		utils.Logger.Sugar().Debugf("Waited %s for a render context", wait)
+		return rc, nil
+	case p.slots <- struct{}{}:
+		wait := time.Since(start)
+		recordPoolWait(wait)
+		utils.Logger.Sugar().Debugf("Waited %s for a render context slot", wait)
+		return p.create()
+	case <-p.done:
+		return nil, errPoolClosed
+	}
+}
+
+// create makes a new context for a slot the caller has taken, freeing the slot
+// again if that fails.
+func (p *contextPool) create() (*renderContext, error) {
+	rc, err := newRenderContext(p.bundle, p.pagePath)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return rc, nil
+}
+
+// release hands a context back to the pool, or disposes of it if the pool has
+// been closed in the meantime.
+func (p *contextPool) release(rc *renderContext) {
+	rc.requestID = ""
+	rc.loop.reset()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		<-p.slots
+		rc.dispose()
+		return
+	}
+	p.idle <- rc
+}
+
+// discard disposes of a context that must not be reused and frees its slot for
+// a waiting caller.
+func (p *contextPool) discard(rc *renderContext) {
+	rc.dispose()
+	<-p.slots
+}
+
+// close disposes of every idle context. Contexts still in use are disposed of
+// when they are released.
+func (p *contextPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.done)
+
+	for {
+		select {
+		case rc := <-p.idle:
+			<-p.slots
+			rc.dispose()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package react
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+)
+
+// BUNDLE_MANIFEST is the file prebuilt page bundles are written to, next to the
+// CLIENT_ASSET_PREFIX directory holding their client chunks.
+const BUNDLE_MANIFEST = "bundles.json"
+
+// prebuiltBundle is a page's server bundle and, for hydrated pages, its client
+// scripts, as built by `ikou build --server`.
+type prebuiltBundle struct {
+	Server    string          `json:"server"`
+	SourceMap json.RawMessage `json:"sourceMap,omitempty"`
+	Script    string          `json:"script,omitempty"`
+	Preloads  []string        `json:"preloads,omitempty"`
+}
+
+// prebuiltBundles holds the bundles loaded by LoadPrebuiltBundles, keyed like
+// the bundle cache.
+var prebuiltBundles = map[string]prebuiltBundle{}
+
+// PrebuildPage builds the bundles of a page into the bundle cache so they are
+// included by WriteBundleManifest.
+//
+// Parameters:
+//   - isSSG: A boolean indicating if the page is rendered without its client script.
+//   - pagePath: The path of the page to be built.
+//   - layouts: The layout files wrapping the page, outermost first.
+//
+// Returns:
+//   - An error if either bundle fails to build or the server bundle fails to evaluate.
+func PrebuildPage(isSSG bool, pagePath string, layouts []string) error {
+	_, err := resolvePageSources(pagePath, layouts).getPageBundles(isSSG)
+	return err
+}
+
+// WriteBundleManifest writes every bundle built so far to BUNDLE_MANIFEST in
+// outputDir, together with the client chunks they load.
+func WriteBundleManifest(outputDir string) error {
+	bundleCacheMu.Lock()
+	entries := make(map[string]*bundleEntry, len(bundleCache))
+	for key, entry := range bundleCache {
+		entries[key] = entry
+	}
+	bundleCacheMu.Unlock()
+
+	bundles := make(map[string]prebuiltBundle, len(entries))
+	for key, entry := range entries {
+		<-entry.ready
+		if entry.err != nil {
+			continue
+		}
+
+		bundle := prebuiltBundle{
+			Server:    entry.server.script,
+			SourceMap: entry.server.rawSourceMap,
+		}
+		if entry.client != nil {
+			bundle.Script = entry.client.script
+			bundle.Preloads = entry.client.preloads
+		}
+		bundles[key] = bundle
+	}
+
+	manifest, err := json.Marshal(bundles)
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle manifest: %w", err)
+	}
+	if err := os.MkdirAll(outputDir, fs.ModePerm); err != nil {
+		return fmt.Errorf("failed to create %s: %w", outputDir, err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, BUNDLE_MANIFEST), manifest, 0644); err != nil {
+		return fmt.Errorf("failed to write bundle manifest: %w", err)
+	}
+
+	return WriteClientAssets(outputDir)
+}
+
+// LoadPrebuiltBundles reads the bundle manifest and client chunks from
+// utils.EmbeddedAssets. Pages are then only rendered from these bundles.
+func LoadPrebuiltBundles() error {
+	manifest, err := fs.ReadFile(utils.EmbeddedAssets, BUNDLE_MANIFEST)
+	if err != nil {
+		return fmt.Errorf("failed to read bundle manifest: %w", err)
+	}
+	if err := json.Unmarshal(manifest, &prebuiltBundles); err != nil {
+		return fmt.Errorf("failed to parse bundle manifest: %w", err)
+	}
+
+	assetDir := strings.Trim(CLIENT_ASSET_PREFIX, "/")
+	err = fs.WalkDir(utils.EmbeddedAssets, assetDir, func(assetPath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		contents, err := fs.ReadFile(utils.EmbeddedAssets, assetPath)
+		if err != nil {
+			return err
+		}
+		// Prebuilt bundles are never evicted, so their chunks are kept for good
+		retainClientAsset(strings.TrimPrefix(assetPath, assetDir+"/"), contents)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to load client assets: %w", err)
+	}
+
+	utils.Logger.Sugar().Infof("Loaded %d prebuilt page bundles", len(prebuiltBundles))
+	return nil
+}
+
+// loadPrebuilt fills the entry from the prebuilt bundle with the same key.
+func (e *bundleEntry) loadPrebuilt(key string, pagePath string) {
+	bundle, exists := prebuiltBundles[key]
+	if !exists {
+		e.err = fmt.Errorf("no prebuilt bundle for %s", pagePath)
+		return
+	}
+
+	e.server = &serverBuild{script: bundle.Server}
+	if len(bundle.SourceMap) > 0 {
+		sm, err := parseServerSourceMap(bundle.SourceMap)
+		if err != nil {
+			utils.Logger.Sugar().Warnf("Ignoring invalid server source map: %v", err)
+		}
+		e.server.sourceMap = sm
+	}
+
+	pool, err := newContextPool(e.server.script, pagePath)
+	if err != nil {
+		e.err = newRenderError(err, e.server.sourceMap)
+		return
+	}
+	e.pool = pool
+
+	if bundle.Script != "" {
+		e.client = &clientBuild{script: bundle.Script, preloads: bundle.Preloads}
+	}
+}
@@ -2,66 +2,88 @@ package react
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
+	"github.com/bendigiorgio/ikou/internal/app/livereload"
 	"github.com/bendigiorgio/ikou/internal/app/utils"
 	esbuild "github.com/evanw/esbuild/pkg/api"
 	"go.uber.org/zap"
-	v8 "rogchap.com/v8go"
 )
 
 // [Yaffle/TextEncoderTextDecoder.js](https://gist.github.com/Yaffle/5458286)
-var textEncoderPolyfill = `function TextEncoder(){} TextEncoder.prototype.encode=function(string){var octets=[],length=string.length,i=0;while(i<length){var codePoint=string.codePointAt(i),c=0,bits=0;codePoint<=0x7F?(c=0,bits=0x00):codePoint<=0x7FF?(c=6,bits=0xC0):codePoint<=0xFFFF?(c=12,bits=0xE0):codePoint<=0x1FFFFF&&(c=18,bits=0xF0),octets.push(bits|(codePoint>>c)),c-=6;while(c>=0){octets.push(0x80|((codePoint>>c)&0x3F)),c-=6}i+=codePoint>=0x10000?2:1}return octets};function TextDecoder(){} TextDecoder.prototype.decode=function(octets){var string="",i=0;while(i<octets.length){var octet=octets[i],bytesNeeded=0,codePoint=0;octet<=0x7F?(bytesNeeded=0,codePoint=octet&0xFF):octet<=0xDF?(bytesNeeded=1,codePoint=octet&0x1F):octet<=0xEF?(bytesNeeded=2,codePoint=octet&0x0F):octet<=0xF4&&(bytesNeeded=3,codePoint=octet&0x07),octets.length-i-bytesNeeded>0?function(){for(var k=0;k<bytesNeeded;){octet=octets[i+k+1],codePoint=(codePoint<<6)|(octet&0x3F),k+=1}}():codePoint=0xFFFD,bytesNeeded=octets.length-i,string+=String.fromCodePoint(codePoint),i+=bytesNeeded+1}return string};`
-var processPolyfill = `var process = {env: {NODE_ENV: "production"}};`
-var consolePolyfill = `var console = {log: function(){}};`
+var textEncoderPolyfill = `function TextEncoder(){} TextEncoder.prototype.encode=function(string){var octets=[],length=string.length,i=0;while(i<length){var codePoint=string.codePointAt(i),c=0,bits=0;codePoint<=0x7F?(c=0,bits=0x00):codePoint<=0x7FF?(c=6,bits=0xC0):codePoint<=0xFFFF?(c=12,bits=0xE0):codePoint<=0x1FFFFF&&(c=18,bits=0xF0),octets.push(bits|(codePoint>>c)),c-=6;while(c>=0){octets.push(0x80|((codePoint>>c)&0x3F)),c-=6}i+=codePoint>=0x10000?2:1}return new Uint8Array(octets)};function TextDecoder(){} TextDecoder.prototype.decode=function(octets){var string="",i=0;while(i<octets.length){var octet=octets[i],bytesNeeded=0,codePoint=0;octet<=0x7F?(bytesNeeded=0,codePoint=octet&0xFF):octet<=0xDF?(bytesNeeded=1,codePoint=octet&0x1F):octet<=0xEF?(bytesNeeded=2,codePoint=octet&0x0F):octet<=0xF4&&(bytesNeeded=3,codePoint=octet&0x07),octets.length-i-bytesNeeded>0?function(){for(var k=0;k<bytesNeeded;){octet=octets[i+k+1],codePoint=(codePoint<<6)|(octet&0x3F),k+=1}}():codePoint=0xFFFD,bytesNeeded=octets.length-i,string+=String.fromCodePoint(codePoint),i+=bytesNeeded+1}return string};`
 
+// readableStreamPolyfill is the subset of ReadableStream that
+// renderToReadableStream and the streaming renderer rely on.
+var readableStreamPolyfill = `if(typeof ReadableStream==="undefined"){globalThis.ReadableStream=function(source){var queue=[],waiting=[],closed=false,failure=null;function settle(){while(waiting.length&&(queue.length||closed||failure)){var pending=waiting.shift();if(queue.length){pending.resolve({value:queue.shift(),done:false})}else if(failure){pending.reject(failure)}else{pending.resolve({value:undefined,done:true})}}}var controller={desiredSize:0,enqueue:function(chunk){queue.push(chunk);settle()},close:function(){closed=true;settle()},error:function(err){failure=err;settle()}};this.getReader=function(){return{read:function(){return new Promise(function(resolve,reject){waiting.push({resolve:resolve,reject:reject});if(!queue.length&&!closed&&!failure&&source.pull){source.pull(controller)}settle()})},cancel:function(reason){closed=true;queue=[];if(source.cancel){source.cancel(reason)}settle();return Promise.resolve()},releaseLock:function(){}}};if(source.start){source.start(controller)}}};`
+
+// ssrHtmlTemplate is the document shell used when the frontend has no
+// document.html, see document.go for the slots it is built from.
 const ssrHtmlTemplate = `
 <!DOCTYPE html>
 <html lang="en">
 <head>
     <meta charset="UTF-8">
-    <title>React App</title>
-	<link href="/public/style.css" rel="stylesheet">
+    {{.HeadTags}}
 </head>
 <body>
-    <div id="app">{{.RenderedContent}}</div>
-	<script type="module">{{.JS}}</script>
+    {{.Body}}
+    {{.PropsScript}}
+    {{.Scripts}}
 </body>
 </html>
 `
 
-const ssrClientHtmlTemplate = `
-<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <title>React App</title>
-	<link href="/public/style.css" rel="stylesheet">
-</head>
-<body>
-    <div id="app">{{.RenderedContent}}</div>
-    <script id="IKOU_PROPS">window.APP_PROPS = {{.InitialProps}};</script>
-	<script type="module">{{.JS}}
-	globalThis.renderClientSide(globalThis.PageComponent, window.APP_PROPS);
-	</script>
-</body>
-</html>
-`
+// Metadata describes the <head> of a page. Pages export it as `metadata`, either
+// as an object or as a function of the page props, and entry handlers can
+// override it by returning a "metadata" key in their data.
+type Metadata struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Canonical   string            `json:"canonical"`
+	Meta        map[string]string `json:"meta"`
+	OpenGraph   map[string]string `json:"openGraph"`
+}
 
 type PageData struct {
 	RenderedContent template.HTML
 	InitialProps    template.JS
-	JS              template.JS
+	Metadata        Metadata
+	Script          string
+	Preloads        []string
+	DevScript       template.JS
 	Tmpl            *template.Template
 }
 
+// Name patterns of the temporary entry files the builds write next to the
+// pages, left out of a bundle's import graph by metafileInputs.
+const (
+	serverEntryTempPattern = "temp_server_entry_*.tsx"
+	clientEntryTempPattern = "temp_client_entry_*.tsx"
+)
+
+// serverBuild is the IIFE bundle evaluated in V8 to render a page.
+type serverBuild struct {
+	script    string
+	sourceMap *sourceMap
+	// rawSourceMap is kept to write the source map into prebuilt bundles.
+	rawSourceMap []byte
+}
+
 type PageProps struct {
-	PageRoute string
-	Data      map[string]interface{}
+	PageRoute  string
+	Params     map[string]string
+	Data       map[string]interface{}
+	StatusCode int    `json:",omitempty"`
+	Error      string `json:",omitempty"`
+	// RequestID tags console output of the render, it is not passed to the page.
+	RequestID string `json:"-"`
 }
 
 // buildBackend compiles the specified TypeScript or TSX file into a single JavaScript bundle using esbuild.
@@ -69,28 +91,33 @@ type PageProps struct {
 //
 // Parameters:
 //   - pagePath: The file path of the TypeScript or TSX entry point to be bundled.
+//   - layouts: The layouts wrapping the page, outermost first.
 //
 // Returns:
-//   - A string containing the contents of the bundled JavaScript file.
+//   - The bundled JavaScript and its source map.
+//   - The source files that make up the bundle's import graph.
 //   - An error if the build process fails or if no output files are generated.
-func buildBackend(serverEntry string, pagePath string, basePath string) (string, error) {
+func buildBackend(serverEntry string, pagePath string, layouts []string, basePath string) (*serverBuild, []string, error) {
 	serverEntryContent, err := os.ReadFile(serverEntry)
 	if err != nil {
-		return "", fmt.Errorf("failed to read server entry: %w", err)
+		return nil, nil, fmt.Errorf("failed to read server entry: %w", err)
 	}
 
 	// Dynamically add an import statement for the target page component
-	importStatement := fmt.Sprintf("import PageComponent from './%s'; globalThis.PageComponent = PageComponent;", filepath.ToSlash(pagePath))
+	importStatement := fmt.Sprintf(
+		"%s\nglobalThis.PageComponent = PageComponent; globalThis.PageMetadata = PageModule.metadata; globalThis.PageStreaming = PageModule.streaming;",
+		pageImports(pagePath, layouts),
+	)
 	combinedContent := fmt.Sprintf("%s\n%s", serverEntryContent, importStatement)
 
-	tmpFile, err := os.CreateTemp(basePath, "temp_server_entry_*.tsx")
+	tmpFile, err := os.CreateTemp(basePath, serverEntryTempPattern)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+		return nil, nil, fmt.Errorf("failed to create temp file: %w", err)
 	}
 	defer os.Remove(tmpFile.Name())
 
 	if _, err = tmpFile.Write([]byte(combinedContent)); err != nil {
-		return "", fmt.Errorf("failed to write to temp file: %w", err)
+		return nil, nil, fmt.Errorf("failed to write to temp file: %w", err)
 	}
 
 	tmpFile.Close()
@@ -106,167 +133,469 @@ func buildBackend(serverEntry string, pagePath string, basePath string) (string,
 		MinifyWhitespace:  true,
 		MinifyIdentifiers: true,
 		MinifySyntax:      true,
-		Metafile:          false,
+		Metafile:          true,
+		Sourcemap:         esbuild.SourceMapExternal,
 		LogLevel:          esbuild.LogLevelError,
 		TreeShaking:       esbuild.TreeShakingTrue,
 		Banner: map[string]string{
-			"js": textEncoderPolyfill + processPolyfill + consolePolyfill,
+			"js": textEncoderPolyfill + readableStreamPolyfill,
 		},
+		Define: envDefines(false),
 		Loader: map[string]esbuild.Loader{
 			".tsx": esbuild.LoaderTSX,
 			".ts":  esbuild.LoaderTS,
 		},
 	})
 
-	if len(result.OutputFiles) == 0 {
-		utils.Logger.Sugar().Fatal("Server build error:", result)
-		return "", fmt.Errorf("no output files from backend build")
+	if len(result.Errors) > 0 {
+		return nil, nil, &BuildError{Target: "Server", Messages: result.Errors}
+	}
+
+	build := &serverBuild{}
+	for _, outputFile := range result.OutputFiles {
+		switch {
+		case strings.HasSuffix(outputFile.Path, ".js"):
+			build.script = string(outputFile.Contents)
+		case strings.HasSuffix(outputFile.Path, ".js.map"):
+			sm, err := parseServerSourceMap(outputFile.Contents)
+			if err != nil {
+				utils.Logger.Sugar().Warnf("Ignoring invalid server source map: %v", err)
+				continue
+			}
+			build.sourceMap = sm
+			build.rawSourceMap = outputFile.Contents
+		}
+	}
+	if build.script == "" {
+		return nil, nil, fmt.Errorf("no output files from backend build")
+	}
+
+	inputs, err := metafileInputs(result.Metafile)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return string(result.OutputFiles[0].Contents), nil
+	return build, inputs, nil
+}
+
+// parseServerSourceMap parses the source map of a server bundle.
+func parseServerSourceMap(contents []byte) (*sourceMap, error) {
+	sm, err := parseSourceMap(contents)
+	if err != nil {
+		return nil, err
+	}
+	// Sources are relative to the output directory, make them relative to the working directory
+	for i, source := range sm.sources {
+		sm.sources[i] = filepath.ToSlash(filepath.Join("out", source))
+	}
+	return sm, nil
 }
 
-// buildClient takes a client entry point file path, uses esbuild to bundle it,
-// and returns the bundled client-side JavaScript as a string. If the build
-// process does not produce any output files, it returns an error.
+// envDefines substitutes NODE_ENV and the IKOU_PUBLIC_ variables into a bundle.
+// Any other process.env lookup is undefined in the client bundle, while the
+// server bundle reads it from the process object of its render context.
+func envDefines(client bool) map[string]string {
+	defines := map[string]string{}
+	for key, value := range utils.PublicEnv() {
+		encoded, _ := json.Marshal(value)
+		defines["process.env."+key] = string(encoded)
+	}
+	if client {
+		defines["process.env"] = "{}"
+	}
+	return defines
+}
+
+// pageImports generates the imports for a page and its layouts, relative to the
+// base path, and composes them into a single PageComponent with the layouts
+// wrapped around the page outermost first. The page module is available as
+// PageModule for its other exports.
+func pageImports(pagePath string, layouts []string) string {
+	var builder strings.Builder
+
+	builder.WriteString("import * as IkouReact from 'react';\n")
+	fmt.Fprintf(&builder, "import IkouPage, * as PageModule from './%s';\n", filepath.ToSlash(pagePath))
+
+	layoutNames := make([]string, len(layouts))
+	for i, layout := range layouts {
+		layoutNames[i] = fmt.Sprintf("IkouLayout%d", i)
+		fmt.Fprintf(&builder, "import %s from './%s';\n", layoutNames[i], filepath.ToSlash(layout))
+	}
+
+	fmt.Fprintf(
+		&builder,
+		"const PageComponent = (props) => [%s].reduceRight((children, Layout) => IkouReact.createElement(Layout, props, children), IkouReact.createElement(IkouPage, props));",
+		strings.Join(layoutNames, ", "),
+	)
+	return builder.String()
+}
+
+// buildClient bundles the hydration code for a page as ES modules with code
+// splitting. The client entry and a generated page entry are built together so
+// that React, react-dom and the client entry land in a shared chunk whose
+// content hash is the same for every page, while the page gets its own chunk.
+// The chunks are registered with the client asset store.
 //
 // Parameters:
 //   - clientEntry: A string representing the file path of the client entry point.
+//   - pagePath: The page to hydrate, relative to basePath.
+//   - layouts: The layouts wrapping the page, outermost first.
+//   - basePath: The directory the generated page entry is written to.
 //
 // Returns:
-//   - A string containing the bundled client-side JavaScript.
+//   - The page's entry script and the chunks to preload.
+//   - The source files that make up the bundle's import graph.
 //   - An error if the build process fails or produces no output files.
-func buildClient(clientEntry string, pagePath string, basePath string) (string, error) {
-	clientEntryContent, err := os.ReadFile(clientEntry)
+func buildClient(clientEntry string, pagePath string, layouts []string, basePath string) (*clientBuild, []string, error) {
+	// Import the client entry rather than inlining it so it is shared between pages
+	pageEntryContent := fmt.Sprintf(
+		"%s\nimport './%s';\nglobalThis.renderClientSide(PageComponent, window.APP_PROPS);",
+		pageImports(pagePath, layouts),
+		filepath.Base(clientEntry),
+	)
+
+	tmpFile, err := os.CreateTemp(basePath, clientEntryTempPattern)
 	if err != nil {
-		return "", fmt.Errorf("failed to read client entry: %w", err)
-	}
-
-	// Dynamically add an import statement for the target page component
-	importStatement := fmt.Sprintf("import PageComponent from './%s'; globalThis.PageComponent = PageComponent;", filepath.ToSlash(pagePath))
-	combinedContent := fmt.Sprintf("%s\n%s", clientEntryContent, importStatement)
-
-	tmpFile, err := os.CreateTemp(basePath, "temp_client_entry_*.tsx")
-	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+		return nil, nil, fmt.Errorf("failed to create temp file: %w", err)
 	}
 	defer os.Remove(tmpFile.Name())
 
-	if _, err = tmpFile.Write([]byte(combinedContent)); err != nil {
-		return "", fmt.Errorf("failed to write to temp file: %w", err)
+	if _, err = tmpFile.Write([]byte(pageEntryContent)); err != nil {
+		return nil, nil, fmt.Errorf("failed to write to temp file: %w", err)
 	}
 
 	tmpFile.Close()
+
+	const outdir = "out"
+	entryOutput := clientEntryName(pagePath)
+
 	clientResult := esbuild.Build(esbuild.BuildOptions{
-		EntryPoints: []string{tmpFile.Name()},
+		EntryPointsAdvanced: []esbuild.EntryPoint{
+			{InputPath: clientEntry, OutputPath: "client"},
+			{InputPath: tmpFile.Name(), OutputPath: entryOutput},
+		},
 		Bundle:      true,
 		Write:       false,
+		Outdir:      outdir,
+		Format:      esbuild.FormatESModule,
+		Splitting:   true,
+		EntryNames:  "[name]-[hash]",
+		ChunkNames:  "chunk-[hash]",
 		TreeShaking: esbuild.TreeShakingTrue,
 		LogLevel:    esbuild.LogLevelError,
 		Target:      esbuild.ESNext,
+		Metafile:    true,
+		Define:      envDefines(true),
 	})
 
+	if len(clientResult.Errors) > 0 {
+		return nil, nil, &BuildError{Target: "Client", Messages: clientResult.Errors}
+	}
 	if len(clientResult.OutputFiles) == 0 {
-		utils.Logger.Sugar().Fatal("Client build error:", clientResult.Errors)
-		return "", fmt.Errorf("no output files from client build")
+		return nil, nil, fmt.Errorf("no output files from client build")
+	}
+
+	absOutdir, err := filepath.Abs(outdir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to resolve client output directory: %w", err)
+	}
+
+	outputs := make(map[string][]byte, len(clientResult.OutputFiles))
+	for _, outputFile := range clientResult.OutputFiles {
+		name, err := filepath.Rel(absOutdir, outputFile.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to resolve client output %s: %w", outputFile.Path, err)
+		}
+		outputs[filepath.ToSlash(name)] = outputFile.Contents
+	}
+
+	build, err := storeClientOutputs(outputs, clientResult.Metafile, outdir, entryOutput)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return string(clientResult.OutputFiles[0].Contents), nil
+	inputs, err := metafileInputs(clientResult.Metafile)
+	if err != nil {
+		releaseClientAssets(build.assets)
+		return nil, nil, err
+	}
+
+	return build, inputs, nil
 }
 
-// RenderPage renders a React page either as a static site generation (SSG) or server-side rendering (SSR).
-//
-// Parameters:
-// - isSSG: A boolean indicating if the page should be rendered as SSG.
-// - clientEntry: The entry point for the client-side bundle.
-// - props: The properties to be passed to the React component.
-// - pagePath: The path of the page to be rendered.
-//
-// Returns:
-// - PageData: A struct containing the rendered HTML content, initial props, JavaScript bundle, and the HTML template.
-// - error: An error if any occurred during the rendering process.
-func RenderPage(isSSG bool, props PageProps, pagePath string) (PageData, error) {
+// pageRender is a page whose bundles are built and whose render context has
+// been acquired. It must be finished with release or fail.
+type pageRender struct {
+	bundles   *bundleEntry
+	rc        *renderContext
+	props     PageProps
+	jsonProps []byte
+	stopWatch func() *LimitError
+}
 
-	var renderedHTML string
-	var err error
+// pageSources are the files the bundles of a page are built from. The page and
+// layouts are relative to basePath, the frontend source directory, and key the
+// bundle cache and the prebuilt bundles.
+type pageSources struct {
+	basePath    string
+	serverEntry string
+	clientEntry string
+	pagePath    string
+	layouts     []string
+}
 
+func resolvePageSources(pagePath string, layouts []string) pageSources {
 	basePath := utils.GlobalConfig.BasePath
 	useSrc := utils.GlobalConfig.UseSrc
 	if useSrc {
 		basePath = path.Join(basePath, "src")
 	}
 
-	clientEntry := path.Join(basePath, "clientEntry.tsx")
-	serverEntry := path.Join(basePath, "serverEntry.tsx")
+	relativeLayouts := make([]string, len(layouts))
+	for i, layout := range layouts {
+		relativeLayouts[i] = strings.TrimPrefix(layout, basePath+"/")
+	}
 
-	pagePath = pagePath[len(basePath+"/"):]
+	return pageSources{
+		basePath:    basePath,
+		serverEntry: path.Join(basePath, "serverEntry.tsx"),
+		clientEntry: path.Join(basePath, "clientEntry.tsx"),
+		pagePath:    strings.TrimPrefix(pagePath, basePath+"/"),
+		layouts:     relativeLayouts,
+	}
+}
+
+// getPageBundles returns the bundles of a page, including the client bundle
+// unless the page is rendered for SSG.
+func (s pageSources) getPageBundles(isSSG bool) (*bundleEntry, error) {
+	return getBundles(s.serverEntry, s.clientEntry, s.pagePath, s.layouts, s.basePath, !isSSG)
+}
+
+// prepareRender builds the bundles for a page and acquires a render context
+// for it.
+func prepareRender(isSSG bool, props PageProps, pagePath string, layouts []string) (*pageRender, error) {
+	sources := resolvePageSources(pagePath, layouts)
 
 	propsWithPage := struct {
 		PageProps
 		PagePath string `json:"pagePath"`
 	}{
 		PageProps: props,
-		PagePath:  pagePath,
+		PagePath:  sources.pagePath,
 	}
 
 	jsonProps, err := json.Marshal(propsWithPage)
 	if err != nil {
-		utils.Logger.Sugar().Fatalf("Failed to marshal props: %v", err)
-		return PageData{}, err
+		utils.Logger.Error("Failed to marshal props", zap.Error(err))
+		return nil, err
 	}
 
-	backendBundle, err := buildBackend(serverEntry, pagePath, basePath)
+	for {
+		bundles, err := sources.getPageBundles(isSSG)
+		if err != nil {
+			utils.Logger.Error("Error building page bundles", zap.Error(err))
+			return nil, err
+		}
+
+		rc, err := bundles.pool.acquire()
+		if err == errPoolClosed {
+			// The bundle was invalidated while we waited, pick up the rebuilt one
+			continue
+		}
+		if err != nil {
+			err = newRenderError(err, bundles.server.sourceMap)
+			utils.Logger.Error("Error running backend bundle", zap.Error(err))
+			return nil, err
+		}
+
+		rc.requestID = props.RequestID
+		return &pageRender{
+			bundles:   bundles,
+			rc:        rc,
+			props:     props,
+			jsonProps: jsonProps,
+			stopWatch: rc.watch(),
+		}, nil
+	}
+}
+
+// release hands the render context back to its pool. It fails if a render
+// limit was hit after the last script returned, and recycles contexts whose
+// heap has grown past the limit.
+func (r *pageRender) release() error {
+	if limitErr := r.stopWatch(); limitErr != nil {
+		return r.limitExceeded(limitErr)
+	}
+
+	if r.rc.exceedsHeap() {
+		utils.Logger.Warn("Recycling render context over the heap limit", r.rc.logFields()...)
+		r.bundles.pool.discard(r.rc)
+		return nil
+	}
+	r.bundles.pool.release(r.rc)
+	return nil
+}
+
+// fail discards the render context, which may be left in a broken state, and
+// returns err resolved through the server source map.
+func (r *pageRender) fail(err error, message string) error {
+	limitErr := r.stopWatch()
+	if limitErr == nil {
+		errors.As(err, &limitErr)
+	}
+	if limitErr != nil {
+		return r.limitExceeded(limitErr)
+	}
+
+	r.bundles.pool.discard(r.rc)
+	err = newRenderError(err, r.bundles.server.sourceMap)
+	utils.Logger.Error(message, zap.Error(err))
+	return err
+}
+
+func (r *pageRender) limitExceeded(limitErr *LimitError) error {
+	r.bundles.pool.discard(r.rc)
+	utils.Logger.Error(
+		"Render terminated",
+		append(r.rc.logFields(), zap.String("limit", limitErr.Limit), zap.String("max", limitErr.Value))...,
+	)
+	return limitErr
+}
+
+// pageData assembles the template data for the rendered page.
+func (r *pageRender) pageData(renderedHTML string, head Metadata) (PageData, error) {
+	tmpl, err := loadDocumentTemplate()
 	if err != nil {
-		utils.Logger.Error("Error building backend bundle", zap.Error(err))
+		utils.Logger.Error("Error parsing document template", zap.Error(err))
 		return PageData{}, err
 	}
 
-	ctx := v8.NewContext(nil)
+	pageData := PageData{
+		RenderedContent: template.HTML(renderedHTML),
+		InitialProps:    template.JS(r.jsonProps),
+		Metadata:        head,
+		Tmpl:            tmpl,
+	}
+
+	if utils.IsDevMode() {
+		pageData.DevScript = template.JS(livereload.ClientScript)
+	}
+
+	if r.bundles.client != nil {
+		pageData.Script = r.bundles.client.script
+		pageData.Preloads = r.bundles.client.preloads
+	}
+
+	return pageData, nil
+}
+
+// renderToString renders the whole page with globalThis.renderApp and releases
+// the render context.
+func (r *pageRender) renderToString() (PageData, error) {
+	renderScript := fmt.Sprintf(`globalThis.renderApp(globalThis.PageComponent, %s);`, r.jsonProps)
+	utils.Logger.Info(renderScript)
+	val, err := r.rc.ctx.RunScript(renderScript, "render.js")
+	if err != nil {
+		return PageData{}, r.fail(err, "Failed to render React component")
+	}
+	renderedHTML := val.String()
 
-	_, err = ctx.RunScript(backendBundle, "bundle.js")
+	head, err := resolveMetadata(r.rc, r.jsonProps, r.props.Data)
 	if err != nil {
-		utils.Logger.Error("Error running backend bundle", zap.Error(err))
+		return PageData{}, r.fail(err, "Failed to resolve page metadata")
+	}
+	if err := r.release(); err != nil {
 		return PageData{}, err
 	}
 
-	renderScript := fmt.Sprintf(`globalThis.renderApp(globalThis.PageComponent, %s);`, jsonProps)
-	utils.Logger.Info(renderScript)
-	val, err := ctx.RunScript(renderScript, "render.js")
+	return r.pageData(renderedHTML, head)
+}
 
+// RenderPage renders a React page either as a static site generation (SSG) or server-side rendering (SSR).
+//
+// Parameters:
+// - isSSG: A boolean indicating if the page should be rendered as SSG.
+// - props: The properties to be passed to the React component.
+// - pagePath: The path of the page to be rendered.
+// - layouts: The layout files wrapping the page, outermost first.
+//
+// Returns:
+// - PageData: A struct containing the rendered HTML content, initial props, JavaScript bundle, and the HTML template.
+// - error: An error if any occurred during the rendering process.
+func RenderPage(isSSG bool, props PageProps, pagePath string, layouts []string) (PageData, error) {
+	render, err := prepareRender(isSSG, props, pagePath, layouts)
 	if err != nil {
-		utils.Logger.Sugar().Fatalf("Failed to render React component: %v", err)
 		return PageData{}, err
 	}
-	renderedHTML = val.String()
 
-	clientBundle := ""
+	return render.renderToString()
+}
 
-	if !isSSG {
-		clientBundle, err = buildClient(clientEntry, pagePath, basePath)
-		if err != nil {
-			utils.Logger.Error("Error building client bundle", zap.Error(err))
-			return PageData{}, err
-		}
+// resolveMetadata evaluates the page's exported metadata against its props and
+// applies any "metadata" override returned by the entry handler.
+func resolveMetadata(rc *renderContext, jsonProps []byte, data map[string]interface{}) (Metadata, error) {
+	metadataScript := fmt.Sprintf(
+		`(function (props) {
+			var metadata = globalThis.PageMetadata;
+			if (typeof metadata === "function") metadata = metadata(props);
+			return JSON.stringify(metadata || {});
+		})(%s);`,
+		jsonProps,
+	)
+
+	val, err := rc.ctx.RunScript(metadataScript, "metadata.js")
+	if err != nil {
+		return Metadata{}, err
 	}
 
-	tmpl, err := template.New("ssrPage").Parse(ssrHtmlTemplate)
+	var metadata Metadata
+	if err := json.Unmarshal([]byte(val.String()), &metadata); err != nil {
+		return Metadata{}, fmt.Errorf("failed to decode page metadata: %w", err)
+	}
+
+	override, exists := data["metadata"]
+	if !exists {
+		return metadata, nil
+	}
+
+	overrideJSON, err := json.Marshal(override)
 	if err != nil {
-		utils.Logger.Sugar().Fatal("Error parsing template:", err)
-		return PageData{}, err
+		return Metadata{}, fmt.Errorf("failed to encode entry metadata: %w", err)
+	}
+	var entryMetadata Metadata
+	if err := json.Unmarshal(overrideJSON, &entryMetadata); err != nil {
+		return Metadata{}, fmt.Errorf("failed to decode entry metadata: %w", err)
 	}
 
-	if !isSSG {
-		tmpl, err = template.New("ssrPage").Parse(ssrClientHtmlTemplate)
-		if err != nil {
-			utils.Logger.Sugar().Fatal("Error parsing client template:", err)
-			return PageData{}, err
-		}
+	return metadata.merge(entryMetadata), nil
+}
+
+// merge returns the metadata with every field set in override replacing its own.
+func (m Metadata) merge(override Metadata) Metadata {
+	if override.Title != "" {
+		m.Title = override.Title
+	}
+	if override.Description != "" {
+		m.Description = override.Description
+	}
+	if override.Canonical != "" {
+		m.Canonical = override.Canonical
 	}
+	m.Meta = mergeStrings(m.Meta, override.Meta)
+	m.OpenGraph = mergeStrings(m.OpenGraph, override.OpenGraph)
+	return m
+}
 
-	return PageData{
-		RenderedContent: template.HTML(renderedHTML),
-		InitialProps:    template.JS(jsonProps),
-		JS:              template.JS(clientBundle),
-		Tmpl:            tmpl,
-	}, nil
+func mergeStrings(base map[string]string, override map[string]string) map[string]string {
+	if len(override) == 0 {
+		return base
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+	return merged
 }
@@ -0,0 +1,595 @@
+package react
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+	"go.uber.org/zap"
+	v8 "rogchap.com/v8go"
+)
+
+// runtimeScript builds the web APIs pages expect on top of the Go functions
+// installed by installRuntime. It is evaluated before the server bundle.
+const runtimeScript = `(function () {
+	var parseURL = globalThis.__ikouParseURL, fetchURL = globalThis.__ikouFetch;
+
+	globalThis.queueMicrotask = function (callback) {
+		Promise.resolve().then(callback);
+	};
+
+	globalThis.atob = function (data) {
+		var result = __ikouAtob(String(data));
+		if (result === null) throw new Error("InvalidCharacterError: the string to be decoded is not correctly encoded");
+		return result;
+	};
+	globalThis.btoa = function (data) {
+		var result = __ikouBtoa(String(data));
+		if (result === null) throw new Error("InvalidCharacterError: the string to be encoded contains characters outside of the Latin1 range");
+		return result;
+	};
+
+	globalThis.structuredClone = function (value) {
+		var seen = new Map();
+		function clone(value) {
+			if (typeof value === "function" || typeof value === "symbol") {
+				throw new TypeError("DataCloneError: " + String(value) + " could not be cloned");
+			}
+			if (value === null || typeof value !== "object") return value;
+			if (seen.has(value)) return seen.get(value);
+
+			var copy;
+			if (value instanceof Date) {
+				copy = new Date(value.getTime());
+			} else if (value instanceof RegExp) {
+				copy = new RegExp(value.source, value.flags);
+			} else if (value instanceof ArrayBuffer) {
+				copy = value.slice(0);
+			} else if (ArrayBuffer.isView(value)) {
+				copy = new value.constructor(value.buffer.slice(0), value.byteOffset, value.length === undefined ? value.byteLength : value.length);
+			} else if (value instanceof Error) {
+				copy = new value.constructor(value.message);
+				copy.stack = value.stack;
+			} else if (value instanceof Map) {
+				copy = new Map();
+				seen.set(value, copy);
+				value.forEach(function (item, key) { copy.set(clone(key), clone(item)); });
+				return copy;
+			} else if (value instanceof Set) {
+				copy = new Set();
+				seen.set(value, copy);
+				value.forEach(function (item) { copy.add(clone(item)); });
+				return copy;
+			} else {
+				copy = Array.isArray(value) ? new Array(value.length) : {};
+				seen.set(value, copy);
+				Object.keys(value).forEach(function (key) { copy[key] = clone(value[key]); });
+				return copy;
+			}
+			seen.set(value, copy);
+			return copy;
+		}
+		return clone(value);
+	};
+
+	function encodeParam(value) {
+		return encodeURIComponent(value).replace(/%20/g, "+");
+	}
+	function decodeParam(value) {
+		return decodeURIComponent(value.replace(/\+/g, " "));
+	}
+
+	function URLSearchParams(init) {
+		this._pairs = [];
+		this._url = null;
+		if (init instanceof URLSearchParams) {
+			this._pairs = init._pairs.map(function (pair) { return pair.slice(); });
+		} else if (Array.isArray(init)) {
+			this._pairs = init.map(function (pair) { return [String(pair[0]), String(pair[1])]; });
+		} else if (init !== null && typeof init === "object") {
+			var pairs = this._pairs;
+			Object.keys(init).forEach(function (key) { pairs.push([key, String(init[key])]); });
+		} else if (init !== undefined) {
+			this._parse(String(init));
+		}
+	}
+	URLSearchParams.prototype._parse = function (query) {
+		this._pairs = [];
+		if (query.charAt(0) === "?") query = query.slice(1);
+		var pairs = this._pairs;
+		query.split("&").forEach(function (part) {
+			if (!part) return;
+			var index = part.indexOf("=");
+			if (index < 0) pairs.push([decodeParam(part), ""]);
+			else pairs.push([decodeParam(part.slice(0, index)), decodeParam(part.slice(index + 1))]);
+		});
+	};
+	URLSearchParams.prototype._update = function () {
+		if (this._url) this._url._query = this.toString();
+	};
+	URLSearchParams.prototype.append = function (name, value) {
+		this._pairs.push([String(name), String(value)]);
+		this._update();
+	};
+	URLSearchParams.prototype.delete = function (name) {
+		this._pairs = this._pairs.filter(function (pair) { return pair[0] !== String(name); });
+		this._update();
+	};
+	URLSearchParams.prototype.get = function (name) {
+		for (var i = 0; i < this._pairs.length; i++) {
+			if (this._pairs[i][0] === String(name)) return this._pairs[i][1];
+		}
+		return null;
+	};
+	URLSearchParams.prototype.getAll = function (name) {
+		return this._pairs.filter(function (pair) { return pair[0] === String(name); }).map(function (pair) { return pair[1]; });
+	};
+	URLSearchParams.prototype.has = function (name) {
+		return this.get(name) !== null;
+	};
+	URLSearchParams.prototype.set = function (name, value) {
+		var found = false;
+		name = String(name);
+		this._pairs = this._pairs.filter(function (pair) {
+			if (pair[0] !== name) return true;
+			if (found) return false;
+			found = true;
+			pair[1] = String(value);
+			return true;
+		});
+		if (!found) this._pairs.push([name, String(value)]);
+		this._update();
+	};
+	URLSearchParams.prototype.sort = function () {
+		this._pairs.sort(function (a, b) { return a[0] < b[0] ? -1 : a[0] > b[0] ? 1 : 0; });
+		this._update();
+	};
+	URLSearchParams.prototype.forEach = function (callback, thisArg) {
+		var self = this;
+		this._pairs.forEach(function (pair) { callback.call(thisArg, pair[1], pair[0], self); });
+	};
+	URLSearchParams.prototype.entries = function () {
+		return this._pairs.map(function (pair) { return pair.slice(); })[Symbol.iterator]();
+	};
+	URLSearchParams.prototype.keys = function () {
+		return this._pairs.map(function (pair) { return pair[0]; })[Symbol.iterator]();
+	};
+	URLSearchParams.prototype.values = function () {
+		return this._pairs.map(function (pair) { return pair[1]; })[Symbol.iterator]();
+	};
+	URLSearchParams.prototype[Symbol.iterator] = URLSearchParams.prototype.entries;
+	URLSearchParams.prototype.toString = function () {
+		return this._pairs.map(function (pair) { return encodeParam(pair[0]) + "=" + encodeParam(pair[1]); }).join("&");
+	};
+	Object.defineProperty(URLSearchParams.prototype, "size", {
+		get: function () { return this._pairs.length; }
+	});
+
+	function URL(input, base) {
+		var parts = parseURL(String(input), base === undefined ? "" : String(base));
+		if (parts === null) throw new TypeError("Invalid URL: " + input);
+		parts = JSON.parse(parts);
+
+		this.protocol = parts.protocol;
+		this.username = parts.username;
+		this.password = parts.password;
+		this.hostname = parts.hostname;
+		this.port = parts.port;
+		this.pathname = parts.pathname;
+		this.hash = parts.hash;
+		this._opaque = parts.opaque;
+		this._query = parts.query;
+		this.searchParams = new URLSearchParams(parts.query);
+		this.searchParams._url = this;
+	}
+	Object.defineProperties(URL.prototype, {
+		search: {
+			get: function () { return this._query ? "?" + this._query : ""; },
+			set: function (value) {
+				this.searchParams._parse(String(value));
+				this._query = String(value).replace(/^\?/, "");
+			}
+		},
+		host: {
+			get: function () { return this.hostname + (this.port ? ":" + this.port : ""); }
+		},
+		origin: {
+			get: function () { return this._opaque ? "null" : this.protocol + "//" + this.host; }
+		},
+		href: {
+			get: function () {
+				if (this._opaque) return this.protocol + this.pathname + this.search + this.hash;
+				var auth = this.username ? this.username + (this.password ? ":" + this.password : "") + "@" : "";
+				return this.protocol + "//" + auth + this.host + this.pathname + this.search + this.hash;
+			}
+		}
+	});
+	URL.prototype.toString = function () { return this.href; };
+	URL.prototype.toJSON = function () { return this.href; };
+	URL.canParse = function (input, base) {
+		return parseURL(String(input), base === undefined ? "" : String(base)) !== null;
+	};
+
+	globalThis.URL = URL;
+	globalThis.URLSearchParams = URLSearchParams;
+
+	function Headers(init) {
+		this._map = {};
+		var self = this;
+		if (init instanceof Headers) {
+			init.forEach(function (value, name) { self.append(name, value); });
+		} else if (Array.isArray(init)) {
+			init.forEach(function (pair) { self.append(pair[0], pair[1]); });
+		} else if (init) {
+			Object.keys(init).forEach(function (name) { self.append(name, init[name]); });
+		}
+	}
+	Headers.prototype.append = function (name, value) {
+		name = String(name).toLowerCase();
+		this._map[name] = name in this._map ? this._map[name] + ", " + value : String(value);
+	};
+	Headers.prototype.set = function (name, value) { this._map[String(name).toLowerCase()] = String(value); };
+	Headers.prototype.get = function (name) {
+		name = String(name).toLowerCase();
+		return name in this._map ? this._map[name] : null;
+	};
+	Headers.prototype.has = function (name) { return String(name).toLowerCase() in this._map; };
+	Headers.prototype.delete = function (name) { delete this._map[String(name).toLowerCase()]; };
+	Headers.prototype.forEach = function (callback, thisArg) {
+		var map = this._map, self = this;
+		Object.keys(map).sort().forEach(function (name) { callback.call(thisArg, map[name], name, self); });
+	};
+	Headers.prototype.entries = function () {
+		var map = this._map;
+		return Object.keys(map).sort().map(function (name) { return [name, map[name]]; })[Symbol.iterator]();
+	};
+	Headers.prototype[Symbol.iterator] = Headers.prototype.entries;
+
+	function Response(body, init) {
+		init = init || {};
+		this._body = body === undefined || body === null ? "" : String(body);
+		this.status = init.status === undefined ? 200 : init.status;
+		this.statusText = init.statusText || "";
+		this.headers = new Headers(init.headers);
+		this.ok = this.status >= 200 && this.status < 300;
+		this.url = init.url || "";
+		this.bodyUsed = false;
+	}
+	Response.prototype.text = function () {
+		if (this.bodyUsed) return Promise.reject(new TypeError("Body has already been consumed"));
+		this.bodyUsed = true;
+		return Promise.resolve(this._body);
+	};
+	Response.prototype.json = function () {
+		return this.text().then(JSON.parse);
+	};
+	Response.prototype.clone = function () {
+		return new Response(this._body, { status: this.status, statusText: this.statusText, headers: this.headers, url: this.url });
+	};
+
+	globalThis.Headers = Headers;
+	globalThis.Response = Response;
+
+	globalThis.fetch = function (input, init) {
+		init = init || {};
+		var request = {
+			url: input instanceof URL ? input.href : typeof input === "object" && input.url ? input.url : String(input),
+			method: (init.method || "GET").toUpperCase(),
+			headers: Array.from(new Headers(init.headers)),
+			body: init.body === undefined || init.body === null ? "" : String(init.body)
+		};
+		return fetchURL(JSON.stringify(request)).then(function (result) {
+			result = JSON.parse(result);
+			return new Response(result.body, result);
+		}, function (message) {
+			throw new TypeError("fetch failed: " + message);
+		});
+	};
+})();`
+
+// processScript defines process.env with every loaded environment variable,
+// server code being the only place the non-public ones are visible.
+func processScript() string {
+	env, _ := json.Marshal(utils.Env)
+	return fmt.Sprintf("globalThis.process = {env: %s};", env)
+}
+
+// eventLoop runs the timers and finished asynchronous work of a render context
+// on the goroutine that renders with it.
+type eventLoop struct {
+	timers map[int32]*timer
+	nextID int32
+	// tasks receives the callbacks of finished asynchronous work, which must run
+	// on the render goroutine as they touch V8.
+	tasks   chan func()
+	pending int
+	// done is closed when the loop is reset so work started by a previous render
+	// is dropped.
+	done chan struct{}
+	// interrupt is closed when a render limit is hit, see watch.
+	interrupt chan struct{}
+}
+
+type timer struct {
+	fn       *v8.Function
+	args     []v8.Valuer
+	due      time.Time
+	interval time.Duration
+}
+
+func newEventLoop() *eventLoop {
+	return &eventLoop{
+		timers:    map[int32]*timer{},
+		tasks:     make(chan func()),
+		done:      make(chan struct{}),
+		interrupt: make(chan struct{}),
+	}
+}
+
+// goAsync runs work on its own goroutine and queues the callback it returns to
+// run on the event loop.
+func (l *eventLoop) goAsync(work func() func()) {
+	l.pending++
+	tasks, done := l.tasks, l.done
+	go func() {
+		task := work()
+		select {
+		case tasks <- task:
+		case <-done:
+		}
+	}()
+}
+
+// reset drops every timer and pending piece of work so they do not leak into
+// the next render.
+func (l *eventLoop) reset() {
+	close(l.done)
+	l.timers = map[int32]*timer{}
+	l.tasks = make(chan func())
+	l.done = make(chan struct{})
+	l.interrupt = make(chan struct{})
+	l.pending = 0
+}
+
+// runNext runs the next finished task or due timer, waiting for one if needed.
+// It returns false when there is nothing left that could ever run, or when the
+// loop is interrupted.
+func (l *eventLoop) runNext(rc *renderContext) bool {
+	select {
+	case <-l.interrupt:
+		return false
+	case task := <-l.tasks:
+		l.pending--
+		task()
+		return true
+	default:
+	}
+
+	var nextID int32
+	var next *timer
+	for id, t := range l.timers {
+		if next == nil || t.due.Before(next.due) || (t.due.Equal(next.due) && id < nextID) {
+			nextID, next = id, t
+		}
+	}
+
+	if next == nil {
+		if l.pending == 0 {
+			return false
+		}
+		select {
+		case <-l.interrupt:
+			return false
+		case task := <-l.tasks:
+			l.pending--
+			task()
+			return true
+		}
+	}
+
+	if wait := time.Until(next.due); wait > 0 {
+		deadline := time.NewTimer(wait)
+		defer deadline.Stop()
+		select {
+		case <-l.interrupt:
+			return false
+		case task := <-l.tasks:
+			l.pending--
+			task()
+			return true
+		case <-deadline.C:
+		}
+	}
+
+	if next.interval > 0 {
+		next.due = time.Now().Add(next.interval)
+	} else {
+		delete(l.timers, nextID)
+	}
+	if _, err := next.fn.Call(v8.Undefined(rc.iso), next.args...); err != nil {
+		utils.Logger.Error("Uncaught error in timer callback", append(rc.logFields(), zap.Error(err))...)
+	}
+	return true
+}
+
+// await runs the event loop until the promise settles and returns its result.
+// Values that are not promises are returned as they are.
+func (rc *renderContext) await(val *v8.Value) (*v8.Value, error) {
+	if !val.IsPromise() {
+		return val, nil
+	}
+	promise, err := val.AsPromise()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		rc.ctx.PerformMicrotaskCheckpoint()
+		switch promise.State() {
+		case v8.Fulfilled:
+			return promise.Result(), nil
+		case v8.Rejected:
+			return nil, promiseError(promise.Result())
+		}
+
+		if !rc.loop.runNext(rc) {
+			return nil, errors.New("render did not finish: a promise is still pending with no work left to run")
+		}
+		if limitErr := rc.checkHeap(); limitErr != nil {
+			return nil, limitErr
+		}
+	}
+}
+
+// installRuntime adds the Go functions behind runtimeScript to the global
+// template of a render context.
+func (rc *renderContext) installRuntime(global *v8.ObjectTemplate) error {
+	functions := map[string]v8.FunctionCallback{
+		"setTimeout":     rc.setTimer(false),
+		"setInterval":    rc.setTimer(true),
+		"clearTimeout":   rc.clearTimer,
+		"clearInterval":  rc.clearTimer,
+		"__ikouAtob":     rc.atob,
+		"__ikouBtoa":     rc.btoa,
+		"__ikouParseURL": rc.parseURL,
+		"__ikouFetch":    rc.fetch,
+	}
+	for name, callback := range functions {
+		if err := global.Set(name, v8.NewFunctionTemplate(rc.iso, callback)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rc *renderContext) setTimer(repeat bool) v8.FunctionCallback {
+	return func(info *v8.FunctionCallbackInfo) *v8.Value {
+		args := info.Args()
+		if len(args) == 0 || !args[0].IsFunction() {
+			return v8.Undefined(rc.iso)
+		}
+		fn, _ := args[0].AsFunction()
+
+		var delay time.Duration
+		if len(args) > 1 && args[1].IsNumber() && args[1].Number() > 0 {
+			delay = time.Duration(args[1].Number() * float64(time.Millisecond))
+		}
+
+		t := &timer{fn: fn, due: time.Now().Add(delay)}
+		if repeat {
+			// Browsers clamp intervals, a zero interval would spin the loop
+			t.interval = max(delay, time.Millisecond)
+		}
+		for _, arg := range args[min(len(args), 2):] {
+			t.args = append(t.args, arg)
+		}
+
+		rc.loop.nextID++
+		rc.loop.timers[rc.loop.nextID] = t
+		id, _ := v8.NewValue(rc.iso, rc.loop.nextID)
+		return id
+	}
+}
+
+func (rc *renderContext) clearTimer(info *v8.FunctionCallbackInfo) *v8.Value {
+	if args := info.Args(); len(args) > 0 && args[0].IsNumber() {
+		delete(rc.loop.timers, args[0].Int32())
+	}
+	return nil
+}
+
+func (rc *renderContext) atob(info *v8.FunctionCallbackInfo) *v8.Value {
+	args := info.Args()
+	if len(args) == 0 {
+		return v8.Null(rc.iso)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(args[0].String())
+	if err != nil {
+		// Unpadded input is accepted by browsers
+		decoded, err = base64.RawStdEncoding.DecodeString(args[0].String())
+	}
+	if err != nil {
+		return v8.Null(rc.iso)
+	}
+
+	binary := make([]rune, len(decoded))
+	for i, b := range decoded {
+		binary[i] = rune(b)
+	}
+	result, _ := v8.NewValue(rc.iso, string(binary))
+	return result
+}
+
+func (rc *renderContext) btoa(info *v8.FunctionCallbackInfo) *v8.Value {
+	args := info.Args()
+	if len(args) == 0 {
+		return v8.Null(rc.iso)
+	}
+
+	var binary []byte
+	for _, char := range args[0].String() {
+		if char > 0xFF {
+			return v8.Null(rc.iso)
+		}
+		binary = append(binary, byte(char))
+	}
+	result, _ := v8.NewValue(rc.iso, base64.StdEncoding.EncodeToString(binary))
+	return result
+}
+
+// parseURL resolves a URL against an optional base and returns its components
+// as JSON, or null when it is not a valid absolute URL.
+func (rc *renderContext) parseURL(info *v8.FunctionCallbackInfo) *v8.Value {
+	args := info.Args()
+	if len(args) < 2 {
+		return v8.Null(rc.iso)
+	}
+
+	parsed, err := url.Parse(args[0].String())
+	if err != nil {
+		return v8.Null(rc.iso)
+	}
+	if base := args[1].String(); base != "" {
+		baseURL, err := url.Parse(base)
+		if err != nil || baseURL.Scheme == "" {
+			return v8.Null(rc.iso)
+		}
+		parsed = baseURL.ResolveReference(parsed)
+	}
+	if parsed.Scheme == "" {
+		return v8.Null(rc.iso)
+	}
+
+	parts := map[string]interface{}{
+		"protocol": parsed.Scheme + ":",
+		"username": parsed.User.Username(),
+		"hostname": parsed.Hostname(),
+		"port":     parsed.Port(),
+		"pathname": parsed.EscapedPath(),
+		"query":    parsed.RawQuery,
+		"hash":     "",
+		"opaque":   parsed.Opaque != "",
+	}
+	if password, ok := parsed.User.Password(); ok {
+		parts["password"] = password
+	} else {
+		parts["password"] = ""
+	}
+	if parsed.Opaque != "" {
+		parts["pathname"] = parsed.Opaque
+	} else if parts["pathname"] == "" {
+		parts["pathname"] = "/"
+	}
+	if parsed.Fragment != "" {
+		parts["hash"] = "#" + parsed.EscapedFragment()
+	}
+
+	encoded, _ := json.Marshal(parts)
+	result, _ := v8.NewValue(rc.iso, string(encoded))
+	return result
+}
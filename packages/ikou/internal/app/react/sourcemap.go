@@ -0,0 +1,143 @@
+package react
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// mapping is a single decoded source map segment. Lines and columns are zero
+// based, as in the source map format.
+type mapping struct {
+	generatedColumn int
+	source          int
+	line            int
+	column          int
+}
+
+// sourceMap resolves positions in a generated bundle back to the original files.
+type sourceMap struct {
+	sources []string
+	lines   [][]mapping
+}
+
+// parseSourceMap decodes the VLQ mappings of a version 3 source map.
+func parseSourceMap(contents []byte) (*sourceMap, error) {
+	var raw struct {
+		Sources  []string `json:"sources"`
+		Mappings string   `json:"mappings"`
+	}
+	if err := json.Unmarshal(contents, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse source map: %w", err)
+	}
+
+	sm := &sourceMap{sources: raw.Sources}
+	source, line, column := 0, 0, 0
+
+	for _, encodedLine := range strings.Split(raw.Mappings, ";") {
+		var segments []mapping
+		generatedColumn := 0
+
+		for _, encodedSegment := range strings.Split(encodedLine, ",") {
+			if encodedSegment == "" {
+				continue
+			}
+			fields, err := decodeVLQ(encodedSegment)
+			if err != nil {
+				return nil, err
+			}
+
+			generatedColumn += fields[0]
+			if len(fields) < 4 {
+				continue
+			}
+			source += fields[1]
+			line += fields[2]
+			column += fields[3]
+
+			segments = append(segments, mapping{
+				generatedColumn: generatedColumn,
+				source:          source,
+				line:            line,
+				column:          column,
+			})
+		}
+
+		sm.lines = append(sm.lines, segments)
+	}
+
+	return sm, nil
+}
+
+func decodeVLQ(segment string) ([]int, error) {
+	var fields []int
+	value, shift := 0, 0
+
+	for _, char := range segment {
+		digit := strings.IndexRune(base64Chars, char)
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid source map character %q", char)
+		}
+
+		value += (digit & 31) << shift
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+
+		if value&1 != 0 {
+			fields = append(fields, -(value >> 1))
+		} else {
+			fields = append(fields, value>>1)
+		}
+		value, shift = 0, 0
+	}
+
+	return fields, nil
+}
+
+// lookup maps a one-based line and column in the generated bundle to the
+// original source file, line and column, also one-based.
+func (sm *sourceMap) lookup(line int, column int) (string, int, int, bool) {
+	if sm == nil || line < 1 || line > len(sm.lines) {
+		return "", 0, 0, false
+	}
+
+	segments := sm.lines[line-1]
+	index := sort.Search(len(segments), func(i int) bool {
+		return segments[i].generatedColumn > column-1
+	}) - 1
+	if index < 0 || segments[index].source >= len(sm.sources) {
+		return "", 0, 0, false
+	}
+
+	segment := segments[index]
+	return sm.sources[segment.source], segment.line + 1, segment.column + 1, true
+}
+
+var bundlePositionPattern = regexp.MustCompile(`bundle\.js:(\d+):(\d+)`)
+
+// rewriteStack replaces bundle.js positions in a V8 stack trace or location
+// with the original source positions.
+func (sm *sourceMap) rewriteStack(stack string) string {
+	if sm == nil {
+		return stack
+	}
+
+	return bundlePositionPattern.ReplaceAllStringFunc(stack, func(position string) string {
+		match := bundlePositionPattern.FindStringSubmatch(position)
+		line, _ := strconv.Atoi(match[1])
+		column, _ := strconv.Atoi(match[2])
+
+		source, sourceLine, sourceColumn, ok := sm.lookup(line, column)
+		if !ok {
+			return position
+		}
+		return fmt.Sprintf("%s:%d:%d", source, sourceLine, sourceColumn)
+	})
+}
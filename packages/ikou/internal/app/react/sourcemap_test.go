@@ -0,0 +1,200 @@
+package react
+
+import (
+	"reflect"
+	"testing"
+)
+
+// esbuildSourceMap was generated by esbuild for a minified IIFE bundle of two
+// TypeScript files, src/a.ts importing greet from src/b.ts:
+//
+//	(()=>{function t(r){return"Hello, "+r}function n(r){let e=t(r);if(!e)throw new Error("no message");return e}n("ikou");})();
+//
+// greet starts on line 13 of b.ts, so switching back to a.ts moves the
+// original line backwards, and the mappings mix single and multi-character
+// VLQ segments.
+const esbuildSourceMap = `{
+  "version": 3,
+  "sources": ["../src/b.ts", "../src/a.ts"],
+  "mappings": "MAYO,SAASA,EAAMC,EAAsB,CAC1C,MAAO,UAAYA,CACrB,CCZO,SAASC,EAAKC,EAAc,CACjC,IAAMC,EAAUC,EAAMF,CAAI,EAC1B,GAAI,CAACC,EACH,MAAM,IAAI,MAAM,YAAY,EAE9B,OAAOA,CACT,CACAF,EAAK,MAAM",
+  "names": ["greet", "name", "main", "name", "message", "greet"]
+}`
+
+func TestDecodeVLQ(t *testing.T) {
+	tests := []struct {
+		name    string
+		segment string
+		want    []int
+		wantErr bool
+	}{
+		{name: "zero", segment: "A", want: []int{0}},
+		{name: "positive", segment: "C", want: []int{1}},
+		{name: "negative", segment: "D", want: []int{-1}},
+		{name: "continuation", segment: "gB", want: []int{16}},
+		{name: "negative continuation", segment: "hB", want: []int{-16}},
+		{name: "large value", segment: "2H", want: []int{123}},
+		{name: "four fields", segment: "MAYO", want: []int{6, 0, 12, 7}},
+		{name: "five fields", segment: "SAASA", want: []int{9, 0, 0, 9, 0}},
+		{name: "multi-character negative field", segment: "CAC1C", want: []int{1, 0, 1, -42}},
+		{name: "source switch with negative line", segment: "CCZO", want: []int{1, 1, -12, 7}},
+		{name: "invalid character", segment: "A*", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := decodeVLQ(test.segment)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("decodeVLQ(%q) = %v, want an error", test.segment, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeVLQ(%q) returned %v", test.segment, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("decodeVLQ(%q) = %v, want %v", test.segment, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseSourceMap(t *testing.T) {
+	sm, err := parseSourceMap([]byte(esbuildSourceMap))
+	if err != nil {
+		t.Fatalf("parseSourceMap returned %v", err)
+	}
+
+	if want := []string{"../src/b.ts", "../src/a.ts"}; !reflect.DeepEqual(sm.sources, want) {
+		t.Errorf("sources = %v, want %v", sm.sources, want)
+	}
+	if len(sm.lines) != 1 {
+		t.Fatalf("decoded %d lines, want 1", len(sm.lines))
+	}
+
+	segments := sm.lines[0]
+	if len(segments) != 31 {
+		t.Errorf("decoded %d segments, want 31", len(segments))
+	}
+
+	// Fields are deltas, so each segment depends on everything before it
+	tests := []struct {
+		index int
+		want  mapping
+	}{
+		{index: 0, want: mapping{generatedColumn: 6, source: 0, line: 12, column: 7}},
+		{index: 4, want: mapping{generatedColumn: 20, source: 0, line: 13, column: 2}},
+		{index: 8, want: mapping{generatedColumn: 38, source: 1, line: 2, column: 7}},
+		{index: 30, want: mapping{generatedColumn: 116, source: 1, line: 9, column: 11}},
+	}
+	for _, test := range tests {
+		if got := segments[test.index]; got != test.want {
+			t.Errorf("segment %d = %+v, want %+v", test.index, got, test.want)
+		}
+	}
+}
+
+func TestParseSourceMapMultipleLines(t *testing.T) {
+	// Generated columns reset on every line, while the source, line and
+	// column deltas carry over, including across empty lines
+	sm, err := parseSourceMap([]byte(`{"sources":["a.ts","b.ts"],"mappings":"AAAA,IAAI;;EACF,ECAA;AAAD"}`))
+	if err != nil {
+		t.Fatalf("parseSourceMap returned %v", err)
+	}
+
+	want := [][]mapping{
+		{{generatedColumn: 0, source: 0, line: 0, column: 0}, {generatedColumn: 4, source: 0, line: 0, column: 4}},
+		nil,
+		{{generatedColumn: 2, source: 0, line: 1, column: 2}, {generatedColumn: 4, source: 1, line: 1, column: 2}},
+		{{generatedColumn: 0, source: 1, line: 1, column: 1}},
+	}
+	if !reflect.DeepEqual(sm.lines, want) {
+		t.Errorf("lines = %+v, want %+v", sm.lines, want)
+	}
+}
+
+func TestParseSourceMapErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{name: "invalid json", contents: `{"mappings":`},
+		{name: "invalid mapping", contents: `{"sources":["a.ts"],"mappings":"AA!A"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parseSourceMap([]byte(test.contents)); err == nil {
+				t.Errorf("parseSourceMap(%s) returned no error", test.contents)
+			}
+		})
+	}
+}
+
+func TestSourceMapLookup(t *testing.T) {
+	sm, err := parseSourceMap([]byte(esbuildSourceMap))
+	if err != nil {
+		t.Fatalf("parseSourceMap returned %v", err)
+	}
+
+	// Lookups take and return one-based positions, the bundle columns below
+	// are the zero-based index of the token plus one
+	tests := []struct {
+		name       string
+		line       int
+		column     int
+		wantSource string
+		wantLine   int
+		wantColumn int
+		wantOK     bool
+	}{
+		{name: "first segment", line: 1, column: 7, wantSource: "../src/b.ts", wantLine: 13, wantColumn: 8, wantOK: true},
+		{name: "greet return", line: 1, column: 21, wantSource: "../src/b.ts", wantLine: 14, wantColumn: 3, wantOK: true},
+		{name: "second source", line: 1, column: 39, wantSource: "../src/a.ts", wantLine: 3, wantColumn: 8, wantOK: true},
+		{name: "throw", line: 1, column: 70, wantSource: "../src/a.ts", wantLine: 6, wantColumn: 5, wantOK: true},
+		{name: "inside a segment", line: 1, column: 73, wantSource: "../src/a.ts", wantLine: 6, wantColumn: 5, wantOK: true},
+		{name: "top level call", line: 1, column: 109, wantSource: "../src/a.ts", wantLine: 10, wantColumn: 1, wantOK: true},
+		{name: "past the last segment", line: 1, column: 500, wantSource: "../src/a.ts", wantLine: 10, wantColumn: 12, wantOK: true},
+		{name: "column before the first segment", line: 1, column: 6},
+		{name: "zero line", line: 0, column: 7},
+		{name: "line past the end", line: 2, column: 7},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source, line, column, ok := sm.lookup(test.line, test.column)
+			if ok != test.wantOK || source != test.wantSource || line != test.wantLine || column != test.wantColumn {
+				t.Errorf("lookup(%d, %d) = %q, %d, %d, %v, want %q, %d, %d, %v",
+					test.line, test.column, source, line, column, ok,
+					test.wantSource, test.wantLine, test.wantColumn, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestSourceMapLookupInvalidSource(t *testing.T) {
+	sm, err := parseSourceMap([]byte(`{"sources":["a.ts"],"mappings":"AAAA,ECAA"}`))
+	if err != nil {
+		t.Fatalf("parseSourceMap returned %v", err)
+	}
+
+	if _, _, _, ok := sm.lookup(1, 3); ok {
+		t.Error("lookup resolved a segment pointing past the sources list")
+	}
+	if _, _, _, ok := (*sourceMap)(nil).lookup(1, 1); ok {
+		t.Error("lookup on a nil source map resolved a position")
+	}
+}
+
+func TestRewriteStack(t *testing.T) {
+	sm, err := parseServerSourceMap([]byte(esbuildSourceMap))
+	if err != nil {
+		t.Fatalf("parseServerSourceMap returned %v", err)
+	}
+
+	stack := "Error: no message\n    at n (bundle.js:1:70)\n    at bundle.js:1:109\n    at other.js:1:70\n    at bundle.js:4:1"
+	want := "Error: no message\n    at n (src/a.ts:6:5)\n    at src/a.ts:10:1\n    at other.js:1:70\n    at bundle.js:4:1"
+	if got := sm.rewriteStack(stack); got != want {
+		t.Errorf("rewriteStack() = %q, want %q", got, want)
+	}
+}
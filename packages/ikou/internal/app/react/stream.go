@@ -0,0 +1,216 @@
+package react
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+	"go.uber.org/zap"
+	v8 "rogchap.com/v8go"
+)
+
+// STREAM_WRITE_FN is the global function through which a streaming render
+// hands its chunks to Go.
+const STREAM_WRITE_FN = "__ikouWrite"
+
+// streamMarker stands in for the page content while the document template is
+// executed, splitting it into the part before and after the streamed body.
+const streamMarker = "<!--ikou-stream-->"
+
+// streamScript renders the page with globalThis.renderAppStream, which must
+// return a ReadableStream (or a promise of one) of UTF-8 bytes, as
+// renderToReadableStream from react-dom/server does. Byte chunks are passed to
+// Go as binary strings with one character per byte, string chunks as they are.
+const streamScript = `(function (props) {
+	function toBinaryString(chunk) {
+		var result = "";
+		for (var i = 0; i < chunk.length; i += 8192) {
+			result += String.fromCharCode.apply(null, chunk.subarray(i, i + 8192));
+		}
+		return result;
+	}
+	return Promise.resolve(globalThis.renderAppStream(globalThis.PageComponent, props)).then(function (stream) {
+		var reader = stream.getReader();
+		function pump() {
+			return reader.read().then(function (result) {
+				if (result.done) return;
+				if (typeof result.value === "string") {
+					` + STREAM_WRITE_FN + `(result.value, false);
+				} else {
+					` + STREAM_WRITE_FN + `(toBinaryString(result.value), true);
+				}
+				return pump();
+			});
+		}
+		return pump();
+	});
+})(%s);`
+
+// writeCallback implements STREAM_WRITE_FN for the render context. The second
+// argument tells binary strings apart from text, which is written as UTF-8.
+func (rc *renderContext) writeCallback(info *v8.FunctionCallbackInfo) *v8.Value {
+	args := info.Args()
+	if rc.write == nil || len(args) == 0 {
+		return nil
+	}
+
+	if len(args) < 2 || !args[1].Boolean() {
+		rc.write([]byte(args[0].String()))
+		return nil
+	}
+
+	binary := args[0].String()
+	chunk := make([]byte, 0, len(binary))
+	for _, char := range binary {
+		chunk = append(chunk, byte(char))
+	}
+	rc.write(chunk)
+	return nil
+}
+
+// isStreaming reports whether the page should be streamed. A page's
+// `export const streaming` takes precedence over the ssr.streaming config, and
+// pages are only streamed when the server entry defines renderAppStream.
+func (r *pageRender) isStreaming() (bool, error) {
+	val, err := r.rc.ctx.RunScript(
+		fmt.Sprintf(
+			`typeof globalThis.renderAppStream === "function" && (typeof globalThis.PageStreaming === "boolean" ? globalThis.PageStreaming : %t);`,
+			utils.GlobalConfig.SSR.Streaming,
+		),
+		"streaming.js",
+	)
+	if err != nil {
+		return false, err
+	}
+	return val.Boolean(), nil
+}
+
+// promiseError converts a rejection reason into a JSError so it is reported
+// like a thrown exception.
+func promiseError(reason *v8.Value) error {
+	jsErr := &v8.JSError{Message: reason.String()}
+	if reason.IsObject() {
+		if stack, err := reason.Object().Get("stack"); err == nil && stack.IsString() {
+			jsErr.StackTrace = stack.String()
+		}
+	}
+	return jsErr
+}
+
+// StreamPage renders a page into w with the given status code. Streamed pages
+// flush the document up to the page body as soon as the React shell is ready
+// and then write each chunk as it is produced, other pages are rendered with
+// RenderPage and written at once.
+//
+// Parameters:
+//   - w: The response to write the page to.
+//   - status: The HTTP status code of the response.
+//   - isSSG: A boolean indicating if the page is rendered without its client script.
+//   - props: The properties to be passed to the React component.
+//   - pagePath: The path of the page to be rendered.
+//   - layouts: The layout files wrapping the page, outermost first.
+//
+// Returns:
+//   - An error if the page failed before anything was written. Failures after
+//     the response started are logged and end the response early, as the status
+//     code can no longer be changed.
+func StreamPage(w http.ResponseWriter, status int, isSSG bool, props PageProps, pagePath string, layouts []string) error {
+	render, err := prepareRender(isSSG, props, pagePath, layouts)
+	if err != nil {
+		return err
+	}
+
+	streaming, err := render.isStreaming()
+	if err != nil {
+		return render.fail(err, "Failed to read page streaming option")
+	}
+
+	if !streaming {
+		pageData, err := render.renderToString()
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		if err := pageData.Tmpl.Execute(w, pageData); err != nil {
+			utils.Logger.Error("Error executing template", zap.Error(err))
+		}
+		return nil
+	}
+
+	return render.stream(w, status)
+}
+
+// stream renders the page with streamScript, writing the document around the
+// chunks, and releases the render context.
+func (r *pageRender) stream(w http.ResponseWriter, status int) error {
+	// Metadata does not depend on the render, so the head can be sent with the shell
+	head, err := resolveMetadata(r.rc, r.jsonProps, r.props.Data)
+	if err != nil {
+		return r.fail(err, "Failed to resolve page metadata")
+	}
+
+	pageData, err := r.pageData(streamMarker, head)
+	if err != nil {
+		return errors.Join(err, r.release())
+	}
+
+	var document bytes.Buffer
+	if err := pageData.Tmpl.Execute(&document, pageData); err != nil {
+		return errors.Join(fmt.Errorf("failed to execute document template: %w", err), r.release())
+	}
+	before, after, found := strings.Cut(document.String(), streamMarker)
+	if !found {
+		return errors.Join(fmt.Errorf("document template does not render the page body"), r.release())
+	}
+
+	flusher, _ := w.(http.Flusher)
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		io.WriteString(w, before)
+	}
+
+	r.rc.write = func(chunk []byte) {
+		start()
+		w.Write(chunk)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	val, err := r.rc.ctx.RunScript(fmt.Sprintf(streamScript, r.jsonProps), "render.js")
+	if err == nil {
+		_, err = r.rc.await(val)
+	}
+	r.rc.write = nil
+
+	if err != nil {
+		err = r.fail(err, "Failed to stream React component")
+	} else {
+		err = r.release()
+	}
+	if err != nil {
+		if !started {
+			return err
+		}
+		// The status code is already sent, all that is left is to end the response
+		return nil
+	}
+
+	start()
+	io.WriteString(w, after)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	return nil
+}
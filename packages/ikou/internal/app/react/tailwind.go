@@ -11,7 +11,8 @@ import (
 func BuildCSS() error {
 	useTailwind := utils.GlobalConfig.UseTailwind
 
-	if !useTailwind {
+	// Server binaries embed the stylesheet built with them
+	if !useTailwind || utils.HasEmbeddedAssets() {
 		return nil
 	}
 
@@ -0,0 +1,166 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+)
+
+// CompiledRoute is a route file built into the server binary by
+// `ikou build --server` instead of being loaded as a plugin. Symbols holds the
+// file's exported Handler, Entry, StaticPaths or Middleware functions.
+type CompiledRoute struct {
+	FilePath string
+	Symbols  map[string]interface{}
+}
+
+var compiledRoutes []CompiledRoute
+
+// RegisterCompiledRoutes makes routing use the given route files instead of
+// compiling the routes directory to plugins. It must be called before the
+// server starts.
+func RegisterCompiledRoutes(routes []CompiledRoute) {
+	compiledRoutes = append([]CompiledRoute{}, routes...)
+}
+
+func usesCompiledRoutes() bool {
+	return compiledRoutes != nil
+}
+
+// loadCompiledRoutes registers the compiled API, middleware and, since they
+// need the page routes to exist, optionally the entry files. It fails if the
+// middleware chain cannot be installed as a whole.
+func loadCompiledRoutes(withEntries bool) error {
+	for _, route := range compiledRoutes {
+		switch {
+		case strings.HasPrefix(route.FilePath, BASE_API_ROUTE+"/"):
+			registerApiRoute(ApiRouteMap, route.FilePath, route.Symbols["Handler"])
+		case strings.HasPrefix(route.FilePath, BASE_ENTRY_ROUTE+"/"):
+			if withEntries {
+				registerEntryRoute(entryFiles, route.FilePath, route.Symbols["Entry"], route.Symbols["StaticPaths"])
+			}
+		}
+	}
+
+	if withEntries {
+		EntryRouteMap = linkEntryRoutes(RouteMap, entryFiles)
+	}
+	if err := loadCompiledMiddleware(); err != nil {
+		return err
+	}
+
+	utils.Logger.Sugar().Infof("Loaded %d compiled route files", len(compiledRoutes))
+	return nil
+}
+
+// loadCompiledMiddleware installs the compiled middleware files as a chain.
+// Like loadMiddleware it fails rather than serving without a middleware, which
+// may be the one guarding every request.
+func loadCompiledMiddleware() error {
+	var middleware []CompiledRoute
+	for _, route := range compiledRoutes {
+		if strings.HasPrefix(route.FilePath, BASE_MIDDLEWARE_ROUTE+"/") {
+			middleware = append(middleware, route)
+		}
+	}
+
+	// Middleware is chained in file name order, as when loaded from plugins
+	sort.Slice(middleware, func(i, j int) bool {
+		return middleware[i].FilePath < middleware[j].FilePath
+	})
+	chain := make([]MiddlewareFn, 0, len(middleware))
+	for _, route := range middleware {
+		middlewareFunc, err := middlewareFromSymbol(route.FilePath, route.Symbols["Middleware"])
+		if err != nil {
+			return err
+		}
+		chain = append(chain, middlewareFunc)
+	}
+	GLOBAL_MIDDLEWARE.Store(&chain)
+	return nil
+}
+
+// PAGE_MANIFEST is the file the page routes of a server binary are written to,
+// as the pages directory is not available to scan at runtime.
+const PAGE_MANIFEST = "routes.json"
+
+// ScanPages maps the pages below baseRoute without loading any route files.
+func ScanPages(baseRoute string) error {
+	return scanDirectory(RouteMap, fmt.Sprintf("%s/pages/", baseRoute), baseRoute)
+}
+
+// WritePageManifest writes the page routes scanned below baseRoute to
+// PAGE_MANIFEST in outputDir. Page and layout paths are stored relative to
+// baseRoute, so the server binary can run with a different basePath.
+func WritePageManifest(baseRoute string, outputDir string) error {
+	routes := make(map[string]RouteInfo, len(RouteMap))
+	for route, routeInfo := range RouteMap {
+		pagePath, err := relativeToBase(baseRoute, routeInfo.PagePath)
+		if err != nil {
+			return err
+		}
+		layouts := make([]string, len(routeInfo.Layouts))
+		for i, layout := range routeInfo.Layouts {
+			if layouts[i], err = relativeToBase(baseRoute, layout); err != nil {
+				return err
+			}
+		}
+		routeInfo.PagePath = pagePath
+		routeInfo.Layouts = layouts
+		routes[route] = routeInfo
+	}
+
+	manifest, err := json.Marshal(routes)
+	if err != nil {
+		return fmt.Errorf("failed to encode page manifest: %w", err)
+	}
+	if err := os.MkdirAll(outputDir, fs.ModePerm); err != nil {
+		return fmt.Errorf("failed to create %s: %w", outputDir, err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, PAGE_MANIFEST), manifest, 0644); err != nil {
+		return fmt.Errorf("failed to write page manifest: %w", err)
+	}
+	return nil
+}
+
+// relativeToBase returns a scanned page or layout path relative to baseRoute,
+// with forward slashes.
+func relativeToBase(baseRoute string, filePath string) (string, error) {
+	relative, err := filepath.Rel(baseRoute, filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s against %s: %w", filePath, baseRoute, err)
+	}
+	return filepath.ToSlash(relative), nil
+}
+
+// loadPageManifest maps the page routes stored in the embedded assets, with
+// their page and layout paths below baseRoute.
+func loadPageManifest(baseRoute string) error {
+	manifest, err := fs.ReadFile(utils.EmbeddedAssets, PAGE_MANIFEST)
+	if err != nil {
+		return fmt.Errorf("failed to read page manifest: %w", err)
+	}
+
+	routes := map[string]RouteInfo{}
+	if err := json.Unmarshal(manifest, &routes); err != nil {
+		return fmt.Errorf("failed to parse page manifest: %w", err)
+	}
+	for route, routeInfo := range routes {
+		routeInfo.PagePath = path.Join(baseRoute, routeInfo.PagePath)
+		for i, layout := range routeInfo.Layouts {
+			routeInfo.Layouts[i] = path.Join(baseRoute, layout)
+		}
+		routeInfo.segments, _ = parseRoutePattern(route)
+		RouteMap[route] = routeInfo
+	}
+
+	utils.Logger.Sugar().Infof("Loaded %d page routes", len(routes))
+	return nil
+}
@@ -0,0 +1,241 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type segmentKind int
+
+const (
+	staticSegment segmentKind = iota
+	paramSegment
+	catchAllSegment
+	optionalCatchAllSegment
+)
+
+type routeSegment struct {
+	kind  segmentKind
+	value string
+}
+
+// parseRoutePattern splits a route such as /blog/[slug] or /docs/[...path] into
+// its segments and returns the names of the dynamic ones.
+func parseRoutePattern(route string) ([]routeSegment, []string) {
+	var segments []routeSegment
+	var names []string
+
+	for _, part := range splitPath(route) {
+		switch {
+		case strings.HasPrefix(part, "[[...") && strings.HasSuffix(part, "]]"):
+			name := part[len("[[...") : len(part)-len("]]")]
+			segments = append(segments, routeSegment{kind: optionalCatchAllSegment, value: name})
+			names = append(names, name)
+		case strings.HasPrefix(part, "[...") && strings.HasSuffix(part, "]"):
+			name := part[len("[...") : len(part)-len("]")]
+			segments = append(segments, routeSegment{kind: catchAllSegment, value: name})
+			names = append(names, name)
+		case strings.HasPrefix(part, "[") && strings.HasSuffix(part, "]"):
+			name := part[1 : len(part)-1]
+			segments = append(segments, routeSegment{kind: paramSegment, value: name})
+			names = append(names, name)
+		default:
+			segments = append(segments, routeSegment{kind: staticSegment, value: part})
+		}
+	}
+
+	return segments, names
+}
+
+func splitPath(route string) []string {
+	var parts []string
+	for _, part := range strings.Split(route, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// matchSegments matches a request path against a parsed route pattern and
+// returns the captured params. Catch-all values are joined with "/" and an
+// empty optional catch-all is left out of the params.
+func matchSegments(segments []routeSegment, route string) (map[string]string, bool) {
+	parts := splitPath(route)
+	params := map[string]string{}
+
+	for i, segment := range segments {
+		switch segment.kind {
+		case staticSegment:
+			if i >= len(parts) || parts[i] != segment.value {
+				return nil, false
+			}
+		case paramSegment:
+			if i >= len(parts) {
+				return nil, false
+			}
+			params[segment.value] = parts[i]
+		case catchAllSegment, optionalCatchAllSegment:
+			if i >= len(parts) {
+				return params, segment.kind == optionalCatchAllSegment
+			}
+			params[segment.value] = strings.Join(parts[i:], "/")
+			return params, true
+		}
+	}
+
+	if len(parts) != len(segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+// compareSpecificity orders two route patterns so that the more specific one
+// comes first: static segments beat params, params beat catch-alls and
+// catch-alls beat optional catch-alls.
+func compareSpecificity(a []routeSegment, b []routeSegment) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].kind != b[i].kind {
+			return a[i].kind < b[i].kind
+		}
+	}
+	return len(a) > len(b)
+}
+
+// routePattern is a dynamic route together with its parsed segments.
+type routePattern struct {
+	route    string
+	segments []routeSegment
+}
+
+// dynamicPages and dynamicApiRoutes hold the dynamic routes of RouteMap and
+// ApiRouteMap from most to least specific, so requests do not parse and sort
+// them again. They are rebuilt by indexRoutes whenever the maps are replaced
+// and guarded by routesMu like the maps themselves.
+var dynamicPages []routePattern
+var dynamicApiRoutes []routePattern
+
+// indexRoutes rebuilds the dynamic route lists from the current maps. It must
+// be called with routesMu held, or before the server starts.
+func indexRoutes() {
+	dynamicPages = indexPageRoutes(RouteMap)
+	dynamicApiRoutes = indexApiRoutes(ApiRouteMap)
+}
+
+// indexPageRoutes returns the dynamic page routes sorted by specificity.
+func indexPageRoutes(pages map[string]RouteInfo) []routePattern {
+	var patterns []routePattern
+	for route, routeInfo := range pages {
+		if routeInfo.IsDynamic {
+			patterns = append(patterns, routePattern{route: route, segments: routeInfo.segments})
+		}
+	}
+	sortPatterns(patterns)
+	return patterns
+}
+
+// indexApiRoutes returns the dynamic API routes sorted by specificity.
+func indexApiRoutes(apiRoutes map[string]map[string]ApiRouteInfo) []routePattern {
+	var patterns []routePattern
+	for route := range apiRoutes {
+		segments, names := parseRoutePattern(route)
+		if len(names) > 0 {
+			patterns = append(patterns, routePattern{route: route, segments: segments})
+		}
+	}
+	sortPatterns(patterns)
+	return patterns
+}
+
+// sortPatterns orders route patterns from most to least specific, falling back
+// to the route itself so the order is stable.
+func sortPatterns(patterns []routePattern) {
+	sort.Slice(patterns, func(i, j int) bool {
+		a, b := patterns[i].segments, patterns[j].segments
+		if compareSpecificity(a, b) == compareSpecificity(b, a) {
+			return patterns[i].route < patterns[j].route
+		}
+		return compareSpecificity(a, b)
+	})
+}
+
+// MatchRoute resolves a request path to a page route. Static routes always win,
+// after which dynamic routes are tried from most to least specific.
+//
+// Returns the RouteMap key of the matched page, its RouteInfo and the params
+// captured from the dynamic segments.
+func MatchRoute(route string) (string, RouteInfo, map[string]string, bool) {
+	routesMu.RLock()
+	defer routesMu.RUnlock()
+
+	if routeInfo, exists := RouteMap[route]; exists && !routeInfo.IsDynamic {
+		return route, routeInfo, map[string]string{}, true
+	}
+
+	for _, pattern := range dynamicPages {
+		if params, ok := matchSegments(pattern.segments, route); ok {
+			return pattern.route, RouteMap[pattern.route], params, true
+		}
+	}
+
+	return "", RouteInfo{}, nil, false
+}
+
+// matchApiRoute resolves a request path to an API route the same way
+// MatchRoute does for pages, so routes/api/users/[id] serves /api/users/42.
+//
+// Returns the ApiRouteMap key, the route's handlers by method and the params
+// captured from the dynamic segments.
+func matchApiRoute(route string) (string, map[string]ApiRouteInfo, map[string]string, bool) {
+	routesMu.RLock()
+	defer routesMu.RUnlock()
+
+	if methods, exists := ApiRouteMap[route]; exists && !strings.Contains(route, "[") {
+		return route, methods, map[string]string{}, true
+	}
+
+	for _, pattern := range dynamicApiRoutes {
+		if params, ok := matchSegments(pattern.segments, route); ok {
+			return pattern.route, ApiRouteMap[pattern.route], params, true
+		}
+	}
+
+	return "", nil, nil, false
+}
+
+// BuildPath fills the dynamic segments of a route pattern with the given params,
+// e.g. /blog/[slug] with slug=hello becomes /blog/hello. Catch-all params may
+// contain slashes and an optional catch-all may be left out. Params must not
+// contain empty, . or .. segments, so the path cannot leave its route.
+func BuildPath(route string, params map[string]string) (string, error) {
+	segments, _ := parseRoutePattern(route)
+
+	parts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if segment.kind == staticSegment {
+			parts = append(parts, segment.value)
+			continue
+		}
+
+		value, exists := params[segment.value]
+		if !exists || value == "" {
+			if segment.kind == optionalCatchAllSegment {
+				continue
+			}
+			return "", fmt.Errorf("missing param %q for route %s", segment.value, route)
+		}
+		if segment.kind == paramSegment && strings.Contains(value, "/") {
+			return "", fmt.Errorf("param %q for route %s must not contain a slash", segment.value, route)
+		}
+		value = strings.Trim(value, "/")
+		for _, part := range strings.Split(value, "/") {
+			if part == "" || part == "." || part == ".." {
+				return "", fmt.Errorf("param %q for route %s must not contain empty, . or .. segments", segment.value, route)
+			}
+		}
+		parts = append(parts, value)
+	}
+
+	return "/" + strings.Join(parts, "/"), nil
+}
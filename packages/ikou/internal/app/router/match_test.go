@@ -0,0 +1,234 @@
+package router
+
+import (
+	"reflect"
+	"testing"
+)
+
+// setRoutes replaces the route maps for the duration of a test.
+func setRoutes(t *testing.T, pageRoutes []string, apiRoutes []string) {
+	t.Helper()
+
+	previousPages, previousApiRoutes := RouteMap, ApiRouteMap
+	t.Cleanup(func() {
+		RouteMap, ApiRouteMap = previousPages, previousApiRoutes
+		indexRoutes()
+	})
+
+	RouteMap = map[string]RouteInfo{}
+	for _, route := range pageRoutes {
+		segments, names := parseRoutePattern(route)
+		RouteMap[route] = RouteInfo{
+			PagePath:     "src/pages" + route + ".page.tsx",
+			IsDynamic:    len(names) > 0,
+			DynamicNames: names,
+			segments:     segments,
+		}
+	}
+	ApiRouteMap = map[string]map[string]ApiRouteInfo{}
+	for _, route := range apiRoutes {
+		ApiRouteMap[route] = map[string]ApiRouteInfo{ANY_METHOD: {FilePath: route, Method: ANY_METHOD}}
+	}
+	indexRoutes()
+}
+
+func TestMatchRoute(t *testing.T) {
+	setRoutes(t, []string{
+		"/",
+		"/blog/new",
+		"/blog/[slug]",
+		"/blog/[slug]/comments",
+		"/docs/[...path]",
+		"/docs/intro",
+		"/shop/[[...filters]]",
+		"/users/[id]",
+		"/users/[...rest]",
+	}, nil)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantRoute  string
+		wantParams map[string]string
+		wantOK     bool
+	}{
+		{name: "index", path: "/", wantRoute: "/", wantParams: map[string]string{}, wantOK: true},
+		{name: "static over dynamic", path: "/blog/new", wantRoute: "/blog/new", wantParams: map[string]string{}, wantOK: true},
+		{name: "param", path: "/blog/hello", wantRoute: "/blog/[slug]", wantParams: map[string]string{"slug": "hello"}, wantOK: true},
+		{name: "param with static suffix", path: "/blog/hello/comments", wantRoute: "/blog/[slug]/comments", wantParams: map[string]string{"slug": "hello"}, wantOK: true},
+		{name: "param does not match extra segments", path: "/blog/hello/likes"},
+		{name: "static over catch-all", path: "/docs/intro", wantRoute: "/docs/intro", wantParams: map[string]string{}, wantOK: true},
+		{name: "catch-all", path: "/docs/guide/routing", wantRoute: "/docs/[...path]", wantParams: map[string]string{"path": "guide/routing"}, wantOK: true},
+		{name: "catch-all needs a segment", path: "/docs"},
+		{name: "param over catch-all", path: "/users/42", wantRoute: "/users/[id]", wantParams: map[string]string{"id": "42"}, wantOK: true},
+		{name: "catch-all takes what the param cannot", path: "/users/42/posts", wantRoute: "/users/[...rest]", wantParams: map[string]string{"rest": "42/posts"}, wantOK: true},
+		{name: "optional catch-all matches the bare prefix", path: "/shop", wantRoute: "/shop/[[...filters]]", wantParams: map[string]string{}, wantOK: true},
+		{name: "optional catch-all with segments", path: "/shop/shoes/red", wantRoute: "/shop/[[...filters]]", wantParams: map[string]string{"filters": "shoes/red"}, wantOK: true},
+		{name: "no match", path: "/missing"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			route, routeInfo, params, ok := MatchRoute(test.path)
+			if ok != test.wantOK || route != test.wantRoute || !reflect.DeepEqual(params, test.wantParams) {
+				t.Fatalf("MatchRoute(%q) = %q, %v, %v, want %q, %v, %v",
+					test.path, route, params, ok, test.wantRoute, test.wantParams, test.wantOK)
+			}
+			if ok && routeInfo.PagePath != RouteMap[route].PagePath {
+				t.Errorf("MatchRoute(%q) returned the RouteInfo of %s", test.path, routeInfo.PagePath)
+			}
+		})
+	}
+}
+
+func TestMatchApiRoute(t *testing.T) {
+	setRoutes(t, nil, []string{
+		"/api/users",
+		"/api/users/me",
+		"/api/users/[id]",
+		"/api/files/[...path]",
+		"/api/search/[[...terms]]",
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantRoute  string
+		wantParams map[string]string
+		wantOK     bool
+	}{
+		{name: "static", path: "/api/users", wantRoute: "/api/users", wantParams: map[string]string{}, wantOK: true},
+		{name: "static over dynamic", path: "/api/users/me", wantRoute: "/api/users/me", wantParams: map[string]string{}, wantOK: true},
+		{name: "param", path: "/api/users/42", wantRoute: "/api/users/[id]", wantParams: map[string]string{"id": "42"}, wantOK: true},
+		{name: "catch-all", path: "/api/files/a/b.txt", wantRoute: "/api/files/[...path]", wantParams: map[string]string{"path": "a/b.txt"}, wantOK: true},
+		{name: "optional catch-all matches the bare prefix", path: "/api/search", wantRoute: "/api/search/[[...terms]]", wantParams: map[string]string{}, wantOK: true},
+		{name: "pattern key is matched like any path", path: "/api/users/[id]", wantRoute: "/api/users/[id]", wantParams: map[string]string{"id": "[id]"}, wantOK: true},
+		{name: "no match", path: "/api/files"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			route, methods, params, ok := matchApiRoute(test.path)
+			if ok != test.wantOK || route != test.wantRoute || !reflect.DeepEqual(params, test.wantParams) {
+				t.Fatalf("matchApiRoute(%q) = %q, %v, %v, want %q, %v, %v",
+					test.path, route, params, ok, test.wantRoute, test.wantParams, test.wantOK)
+			}
+			if ok && methods[ANY_METHOD].FilePath != route {
+				t.Errorf("matchApiRoute(%q) returned the handlers of %s", test.path, methods[ANY_METHOD].FilePath)
+			}
+		})
+	}
+}
+
+func TestCompareSpecificity(t *testing.T) {
+	routes := []string{
+		"/[[...all]]",
+		"/[...all]",
+		"/blog/[[...rest]]",
+		"/blog/[...rest]",
+		"/blog/[slug]",
+		"/blog/[slug]/edit",
+		"/[section]/latest",
+	}
+	pages := map[string]RouteInfo{}
+	for _, route := range routes {
+		segments, names := parseRoutePattern(route)
+		pages[route] = RouteInfo{IsDynamic: len(names) > 0, segments: segments}
+	}
+
+	var got []string
+	for _, pattern := range indexPageRoutes(pages) {
+		got = append(got, pattern.route)
+	}
+	want := []string{
+		"/blog/[slug]/edit",
+		"/blog/[slug]",
+		"/blog/[...rest]",
+		"/blog/[[...rest]]",
+		"/[section]/latest",
+		"/[...all]",
+		"/[[...all]]",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dynamic routes sorted as %v, want %v", got, want)
+	}
+}
+
+func TestBuildPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		route   string
+		params  map[string]string
+		want    string
+		wantErr bool
+	}{
+		{name: "static", route: "/about", want: "/about"},
+		{name: "param", route: "/blog/[slug]", params: map[string]string{"slug": "hello"}, want: "/blog/hello"},
+		{name: "several params", route: "/[lang]/blog/[slug]", params: map[string]string{"lang": "en", "slug": "hello"}, want: "/en/blog/hello"},
+		{name: "catch-all", route: "/docs/[...path]", params: map[string]string{"path": "guide/routing"}, want: "/docs/guide/routing"},
+		{name: "catch-all trims slashes", route: "/docs/[...path]", params: map[string]string{"path": "/guide/"}, want: "/docs/guide"},
+		{name: "optional catch-all", route: "/shop/[[...filters]]", params: map[string]string{"filters": "shoes/red"}, want: "/shop/shoes/red"},
+		{name: "optional catch-all left out", route: "/shop/[[...filters]]", want: "/shop"},
+		{name: "missing param", route: "/blog/[slug]", wantErr: true},
+		{name: "empty param", route: "/blog/[slug]", params: map[string]string{"slug": ""}, wantErr: true},
+		{name: "missing catch-all", route: "/docs/[...path]", wantErr: true},
+		{name: "param with a slash", route: "/blog/[slug]", params: map[string]string{"slug": "a/b"}, wantErr: true},
+		{name: "param with a dot", route: "/docs/[version]", params: map[string]string{"version": "v1.2"}, want: "/docs/v1.2"},
+		{name: "dot param", route: "/blog/[slug]", params: map[string]string{"slug": "."}, wantErr: true},
+		{name: "dot dot param", route: "/blog/[slug]", params: map[string]string{"slug": ".."}, wantErr: true},
+		{name: "catch-all leaving the route", route: "/docs/[...path]", params: map[string]string{"path": "../../etc"}, wantErr: true},
+		{name: "catch-all with a dot segment", route: "/docs/[...path]", params: map[string]string{"path": "a/./b"}, wantErr: true},
+		{name: "catch-all with an empty segment", route: "/docs/[...path]", params: map[string]string{"path": "a//b"}, wantErr: true},
+		{name: "catch-all of slashes only", route: "/docs/[...path]", params: map[string]string{"path": "/"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := BuildPath(test.route, test.params)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("BuildPath(%q, %v) = %q, want an error", test.route, test.params, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuildPath(%q, %v) returned %v", test.route, test.params, err)
+			}
+			if got != test.want {
+				t.Errorf("BuildPath(%q, %v) = %q, want %q", test.route, test.params, got, test.want)
+			}
+		})
+	}
+}
+
+func TestBuildPathRoundTrip(t *testing.T) {
+	tests := []struct {
+		route  string
+		params map[string]string
+	}{
+		{route: "/", params: map[string]string{}},
+		{route: "/blog/[slug]", params: map[string]string{"slug": "hello"}},
+		{route: "/[lang]/blog/[slug]/comments", params: map[string]string{"lang": "en", "slug": "hello"}},
+		{route: "/docs/[...path]", params: map[string]string{"path": "guide/routing/dynamic"}},
+		{route: "/shop/[[...filters]]", params: map[string]string{"filters": "shoes/red"}},
+		{route: "/shop/[[...filters]]", params: map[string]string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.route, func(t *testing.T) {
+			path, err := BuildPath(test.route, test.params)
+			if err != nil {
+				t.Fatalf("BuildPath(%q, %v) returned %v", test.route, test.params, err)
+			}
+
+			segments, _ := parseRoutePattern(test.route)
+			params, ok := matchSegments(segments, path)
+			if !ok {
+				t.Fatalf("%s built from %s does not match it", path, test.route)
+			}
+			if !reflect.DeepEqual(params, test.params) {
+				t.Errorf("%s matched %s with %v, want %v", path, test.route, params, test.params)
+			}
+		})
+	}
+}
@@ -1,50 +1,128 @@
 package router
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"plugin"
+	"sort"
+	"strings"
+	"sync/atomic"
 
+	"github.com/bendigiorgio/ikou/internal/app/livereload"
 	"github.com/bendigiorgio/ikou/internal/app/utils"
 	"github.com/fsnotify/fsnotify"
 )
 
-const MIDDLEWARE_PATH = "routes/middleware/middleware.go"
+const BASE_MIDDLEWARE_ROUTE = "routes/middleware"
 
-var GLOBAL_MIDDLEWARE func(http.Handler) http.Handler
+// REQUEST_ID_HEADER carries the ID that ties log entries to a request. An ID
+// sent by a proxy is kept, otherwise one is generated.
+const REQUEST_ID_HEADER = "X-Request-ID"
 
+type MiddlewareFn func(http.Handler) http.Handler
+
+// GLOBAL_MIDDLEWARE holds the ordered middleware chain loaded from
+// BASE_MIDDLEWARE_ROUTE. It is swapped as a whole whenever the chain is reloaded.
+var GLOBAL_MIDDLEWARE atomic.Pointer[[]MiddlewareFn]
+
+// ApplyMiddleware wraps the handler with the current middleware chain. The chain
+// is looked up on every request so reloads take effect without a restart.
+func ApplyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(REQUEST_ID_HEADER) == "" {
+			r.Header.Set(REQUEST_ID_HEADER, newRequestID())
+		}
+		w.Header().Set(REQUEST_ID_HEADER, r.Header.Get(REQUEST_ID_HEADER))
+
+		chain := GLOBAL_MIDDLEWARE.Load()
+		if chain == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		handler := next
+		for i := len(*chain) - 1; i >= 0; i-- {
+			handler = (*chain)[i](handler)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// loadMiddleware compiles every Go file in BASE_MIDDLEWARE_ROUTE and installs
+// their Middleware functions as a chain ordered by file name, so that
+// 01_logging.go wraps 02_auth.go. The previous chain is kept if any file fails.
+// An edited file compiles to a new plugin path, so its new code is loaded, and
+// the plugin it replaces is deleted.
 func loadMiddleware() error {
-	if _, err := os.Stat(MIDDLEWARE_PATH); os.IsNotExist(err) {
+	entries, err := os.ReadDir(BASE_MIDDLEWARE_ROUTE)
+	if os.IsNotExist(err) {
 		return nil
 	}
-	pluginPath, err := compileToPlugin(MIDDLEWARE_PATH)
 	if err != nil {
-		utils.Logger.Sugar().Errorf("Failed to compile middleware to plugin: %v", err)
 		return err
 	}
 
+	var files []string
+	for _, entry := range entries {
+		entryPath := filepath.Join(BASE_MIDDLEWARE_ROUTE, entry.Name())
+		if info, err := entry.Info(); err == nil && isStalePlugin(entryPath, info) {
+			removePlugin(entryPath)
+			continue
+		}
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") {
+			files = append(files, filepath.Join(BASE_MIDDLEWARE_ROUTE, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	chain := make([]MiddlewareFn, 0, len(files))
+	for _, file := range files {
+		middlewareFunc, err := loadMiddlewareFile(file)
+		if err != nil {
+			return err
+		}
+		chain = append(chain, middlewareFunc)
+	}
+
+	GLOBAL_MIDDLEWARE.Store(&chain)
+	utils.Logger.Sugar().Infof("Loaded %d middleware successfully", len(chain))
+	return nil
+}
+
+func loadMiddlewareFile(filePath string) (MiddlewareFn, error) {
+	pluginPath, err := compileToPlugin(filePath)
+	if err != nil {
+		utils.Logger.Sugar().Errorf("Failed to compile middleware %s to plugin: %v", filePath, err)
+		return nil, err
+	}
+
 	p, err := plugin.Open(pluginPath)
 	if err != nil {
-		utils.Logger.Sugar().Errorf("Failed to load middleware plugin: %v", err)
-		return err
+		utils.Logger.Sugar().Errorf("Failed to load middleware plugin %s: %v", pluginPath, err)
+		return nil, err
 	}
 
 	middlewareSymbol, err := p.Lookup("Middleware")
 	if err != nil {
-		utils.Logger.Sugar().Errorf("Failed to find Middleware function in plugin: %v", err)
-		return err
+		utils.Logger.Sugar().Errorf("Failed to find Middleware function in %s: %v", pluginPath, err)
+		return nil, err
 	}
 
+	return middlewareFromSymbol(filePath, middlewareSymbol)
+}
+
+func middlewareFromSymbol(filePath string, middlewareSymbol interface{}) (MiddlewareFn, error) {
 	middlewareFunc, ok := middlewareSymbol.(func(http.Handler) http.Handler)
 	if !ok {
-		utils.Logger.Sugar().Errorf("Middleware function has an incorrect signature")
-		return err
+		utils.Logger.Sugar().Errorf("Middleware function in %s has an incorrect signature", filePath)
+		return nil, fmt.Errorf("middleware in %s has an incorrect signature", filePath)
 	}
 
-	GLOBAL_MIDDLEWARE = middlewareFunc
-	utils.Logger.Sugar().Info("Loaded middleware successfully")
-	return nil
+	return middlewareFunc, nil
 }
 
 func watchMiddlewareDirectory() {
@@ -61,12 +139,17 @@ func watchMiddlewareDirectory() {
 				if !ok {
 					return
 				}
-				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
+				if !strings.HasSuffix(event.Name, ".go") {
+					continue
+				}
+				if isRouteEvent(event, ".go") {
 					utils.Logger.Sugar().Infof("Middleware file changed: %s", event.Name)
 					err := loadMiddleware()
 					if err != nil {
 						utils.Logger.Sugar().Errorf("Failed to reload middleware: %v", err)
+						continue
 					}
+					livereload.Broadcast(livereload.EventReload)
 				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
@@ -77,9 +160,15 @@ func watchMiddlewareDirectory() {
 		}
 	}()
 
-	err = watcher.Add(filepath.Dir(MIDDLEWARE_PATH))
+	err = watcher.Add(BASE_MIDDLEWARE_ROUTE)
 	if err != nil {
 		utils.Logger.Sugar().Fatalf("Failed to watch middleware directory: %v", err)
 	}
 	select {}
 }
+
+func newRequestID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
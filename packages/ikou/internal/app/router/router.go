@@ -7,10 +7,14 @@ import (
 	"path/filepath"
 	"plugin"
 	"strings"
+	"sync"
 
+	"github.com/bendigiorgio/ikou/api"
+	"github.com/bendigiorgio/ikou/internal/app/livereload"
 	"github.com/bendigiorgio/ikou/internal/app/react"
 	"github.com/bendigiorgio/ikou/internal/app/utils"
 	"github.com/fsnotify/fsnotify"
+	"github.com/gorilla/mux"
 )
 
 type RouteInfo struct {
@@ -18,130 +22,375 @@ type RouteInfo struct {
 	IsSSG        bool
 	IsDynamic    bool
 	DynamicNames []string
+	Layouts      []string
+	segments     []routeSegment
 }
 
 type ApiHandlerFn func(http.ResponseWriter, *http.Request, string)
 
+// ApiRouteInfo describes an API handler file. Legacy handlers set HandlerFn,
+// handlers written against the api package set Handler.
 type ApiRouteInfo struct {
 	FilePath  string
 	Method    string
 	HandlerFn ApiHandlerFn
+	Handler   api.HandlerFunc
 }
 
 type EntryRouteFn func(http.ResponseWriter, *http.Request, string) map[string]interface{}
 
+// StaticPath is one set of params a dynamic page should be pre-rendered with
+// during `ikou build`, together with the props for that path. It is an alias of
+// an unnamed struct so entry plugins can declare the same type without
+// importing this package.
+type StaticPath = struct {
+	Params map[string]string
+	Props  map[string]interface{}
+}
+
+type StaticPathsFn func() []StaticPath
+
 type EntryRouteInfo struct {
-	FilePath  string
-	HandlerFn EntryRouteFn
-	Route     *RouteInfo
+	FilePath      string
+	HandlerFn     EntryRouteFn
+	StaticPathsFn StaticPathsFn
+	Route         *RouteInfo
 }
 
 var RouteMap = map[string]RouteInfo{}
-var ApiRouteMap = map[string]ApiRouteInfo{}
+
+// ApiRouteMap holds the handlers of each API route keyed by method, where
+// ANY_METHOD is the catch-all handler.
+var ApiRouteMap = map[string]map[string]ApiRouteInfo{}
 var EntryRouteMap = map[string]EntryRouteInfo{}
 
+// entryFiles holds every loaded entry file by page route, including those
+// without a page, so EntryRouteMap can be relinked when the pages change.
+var entryFiles = map[string]EntryRouteInfo{}
+
+// routesMu guards RouteMap, ApiRouteMap, EntryRouteMap, entryFiles and the
+// dynamic route lists built from them. The dev watchers rebuild the maps from
+// scratch and swap them in while holding it, so a published map is never
+// modified.
+var routesMu sync.RWMutex
+
+// reloadMu serializes the dev watchers' reloads so an older scan cannot replace
+// a newer one.
+var reloadMu sync.Mutex
+
+// apiPlugins and entryPlugins hold the plugin loaded for each API and entry
+// file by source path, so a reload only recompiles the files that changed and
+// rebuilds the maps from the rest. They are only used while holding reloadMu
+// once the server is running.
+var apiPlugins = map[string]routePlugin{}
+var entryPlugins = map[string]routePlugin{}
+
 const BASE_API_ROUTE = "routes/api"
 const BASE_ENTRY_ROUTE = "routes/entry"
 
-func scanApiDirectory() error {
-	return filepath.Walk(BASE_API_ROUTE, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && strings.HasSuffix(path, ".go") {
-			// Compile and generate API route
-			pluginPath, err := compileToPlugin(path)
-			if err == nil {
-				generateApiRoute(pluginPath)
-			}
-		}
-		return nil
-	})
+// ANY_METHOD is the method key of an API route's catch-all handler, defined in
+// any.go or handler.go. It receives every method without a file of its own.
+const ANY_METHOD = "*"
+
+// API_METHODS are the methods an API handler file can be named after, e.g.
+// routes/api/users/post.go, in the order they are listed in Allow headers.
+var API_METHODS = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
 }
 
-func generateApiRoute(filePath string) {
-	apiPath := utils.GlobalConfig.ApiPath
+var ANY_METHOD_FILES = []string{"any", "handler"}
 
-	fileName := filepath.Base(filePath)
-	method := strings.ToUpper(strings.TrimSuffix(fileName, filepath.Ext(fileName))) // e.g., "get" becomes "GET"
+// Routes of the pages rendered for failed requests, e.g. pages/404.page.tsx.
+// ERROR_ROUTE is used for any status without a page of its own.
+const NOT_FOUND_ROUTE = "/404"
+const SERVER_ERROR_ROUTE = "/500"
+const ERROR_ROUTE = "/_error"
 
-	// Generate route by removing BASE_API_ROUTE and the method part of the path
-	routeDir := filepath.Dir(strings.TrimPrefix(filePath, BASE_API_ROUTE+"/"))
-	route := apiPath + "/" + filepath.ToSlash(routeDir)
+// LAYOUT_FILES are the file names picked up as layouts in the pages directory.
+var LAYOUT_FILES = []string{"layout.tsx", "layout.jsx"}
+
+// BUILD_MODE_HEADER is set on the synthetic requests entry handlers receive
+// while `ikou build` pre-renders pages.
+const BUILD_MODE_HEADER = "X-Ikou-Build"
 
-	// Load the plugin and look up the Handler function
-	p, err := plugin.Open(filePath)
+// scanApiDirectory compiles and loads every file in BASE_API_ROUTE, then maps
+// the loaded handlers into apiRoutes.
+func scanApiDirectory(apiRoutes map[string]map[string]ApiRouteInfo) error {
+	if err := loadPlugins(apiPlugins, BASE_API_ROUTE, openApiPlugin); err != nil {
+		return err
+	}
+	registerApiPlugins(apiRoutes)
+	return nil
+}
+
+// openApiPlugin loads the Handler function of a compiled API file.
+func openApiPlugin(pluginPath string) (map[string]interface{}, error) {
+	p, err := plugin.Open(pluginPath)
 	if err != nil {
-		utils.Logger.Sugar().Errorf("Failed to load API plugin %s: %v", filePath, err)
-		return
+		utils.Logger.Sugar().Errorf("Failed to load API plugin %s: %v", pluginPath, err)
+		return nil, err
 	}
 	handlerSymbol, err := p.Lookup("Handler")
 	if err != nil {
-		utils.Logger.Sugar().Errorf("Failed to find Handler in %s: %v", filePath, err)
-		return
+		utils.Logger.Sugar().Errorf("Failed to find Handler in %s: %v", pluginPath, err)
+		return nil, err
 	}
-	handler, ok := handlerSymbol.(func(http.ResponseWriter, *http.Request, string))
+
+	return map[string]interface{}{"Handler": handlerSymbol}, nil
+}
+
+// registerApiPlugins maps the handlers of every loaded API plugin into
+// apiRoutes, in path order so replaced handlers are reported consistently.
+func registerApiPlugins(apiRoutes map[string]map[string]ApiRouteInfo) {
+	for _, sourcePath := range sortedPluginSources(apiPlugins) {
+		registerApiRoute(apiRoutes, sourcePath, apiPlugins[sourcePath].symbols["Handler"])
+	}
+}
+
+// registerApiRoute maps the Handler of an API file, loaded from a plugin or
+// compiled into the binary, to the route and method given by its path.
+func registerApiRoute(apiRoutes map[string]map[string]ApiRouteInfo, filePath string, handlerSymbol interface{}) {
+	apiPath := utils.GlobalConfig.ApiPath
+
+	fileName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	method, ok := apiMethodFromFile(fileName) // e.g., "get" becomes "GET"
 	if !ok {
+		utils.Logger.Sugar().Errorf("API file %s is not named after an HTTP method, any or handler", filePath)
+		return
+	}
+
+	// Generate route by removing BASE_API_ROUTE and the method part of the path
+	routeDir := filepath.Dir(strings.TrimPrefix(filePath, BASE_API_ROUTE+"/"))
+	route := apiPath
+	if routeDir != "." {
+		route = apiPath + "/" + filepath.ToSlash(routeDir)
+	}
+
+	apiRouteInfo := ApiRouteInfo{
+		FilePath: filePath,
+		Method:   method,
+	}
+	switch handler := handlerSymbol.(type) {
+	case func(*api.Context) error:
+		apiRouteInfo.Handler = handler
+	case func(http.ResponseWriter, *http.Request, string):
+		apiRouteInfo.HandlerFn = handler
+	default:
 		utils.Logger.Sugar().Errorf("Handler in %s has an incorrect signature", filePath)
 		return
 	}
 
-	ApiRouteMap[route] = ApiRouteInfo{
-		FilePath:  filePath,
-		Method:    method,
-		HandlerFn: handler,
+	methods, exists := apiRoutes[route]
+	if !exists {
+		methods = map[string]ApiRouteInfo{}
+		apiRoutes[route] = methods
+	}
+	if existing, exists := methods[method]; exists && existing.FilePath != filePath {
+		utils.Logger.Sugar().Warnf("%s replaces %s for %s %s", filePath, existing.FilePath, method, route)
 	}
+	methods[method] = apiRouteInfo
 
 	utils.Logger.Sugar().Debugf("Mapped API route: %s %s -> %s", method, route, filePath)
 }
 
-// Scan and generate routes for Entry handlers
-func scanEntryDirectory() error {
-	return filepath.Walk(BASE_ENTRY_ROUTE, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// apiMethodFromFile returns the method an API handler file serves, ANY_METHOD
+// for the catch-all files.
+func apiMethodFromFile(fileName string) (string, bool) {
+	for _, name := range ANY_METHOD_FILES {
+		if strings.EqualFold(fileName, name) {
+			return ANY_METHOD, true
 		}
-		if !info.IsDir() && strings.HasSuffix(path, ".go") {
-			// Compile and generate Entry route
-			pluginPath, err := compileToPlugin(path)
-			if err == nil {
-				generateEntryRoute(pluginPath)
-			}
+	}
+	for _, method := range API_METHODS {
+		if strings.EqualFold(fileName, method) {
+			return method, true
 		}
-		return nil
-	})
+	}
+	return "", false
 }
 
-func generateEntryRoute(filePath string) {
-	route := strings.TrimSuffix(strings.TrimPrefix(filePath, BASE_ENTRY_ROUTE+"/"), ".so")
+// HandleApiRoute dispatches the request to the API handler registered for the
+// route and method. HEAD falls back to the GET handler, unmatched methods go to
+// the catch-all handler, and without one OPTIONS is answered with the Allow
+// header and any other method with a 405. It returns false when no API route
+// matches so the caller can fall through to its own handling.
+func HandleApiRoute(w http.ResponseWriter, r *http.Request, route string) bool {
+	routeKey, methods, params, exists := matchApiRoute(route)
+	if !exists {
+		return false
+	}
+
+	apiRouteInfo, exists := methods[r.Method]
+	if !exists && r.Method == http.MethodHead {
+		apiRouteInfo, exists = methods[http.MethodGet]
+	}
+	if !exists {
+		apiRouteInfo, exists = methods[ANY_METHOD]
+	}
+	if !exists {
+		w.Header().Set("Allow", allowedMethods(methods))
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return true
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return true
+	}
+
+	if apiRouteInfo.Handler == nil {
+		apiRouteInfo.HandlerFn(w, SetRouteParams(r, params), apiRouteInfo.FilePath)
+		return true
+	}
 
-	// Load the plugin and look up the Entry function
-	p, err := plugin.Open(filePath)
-	if err != nil {
-		utils.Logger.Sugar().Errorf("Failed to load Entry plugin %s: %v", filePath, err)
-		return
+	ctx := api.NewContext(w, r, routeKey, params)
+	if err := apiRouteInfo.Handler(ctx); err != nil {
+		utils.Logger.Sugar().Errorf("API handler %s failed: %v", apiRouteInfo.FilePath, err)
+		ctx.WriteError(err)
 	}
-	entrySymbol, err := p.Lookup("Entry")
+	return true
+}
+
+// allowedMethods lists the methods an API route answers, for the Allow header.
+func allowedMethods(methods map[string]ApiRouteInfo) string {
+	var allowed []string
+	for _, method := range API_METHODS {
+		_, exists := methods[method]
+		if method == http.MethodHead {
+			_, hasGet := methods[http.MethodGet]
+			exists = exists || hasGet
+		}
+		if exists || method == http.MethodOptions {
+			allowed = append(allowed, method)
+		}
+	}
+	return strings.Join(allowed, ", ")
+}
+
+// scanEntryDirectory compiles and loads every file in BASE_ENTRY_ROUTE, then
+// maps the loaded functions into entries.
+func scanEntryDirectory(entries map[string]EntryRouteInfo) error {
+	if err := loadPlugins(entryPlugins, BASE_ENTRY_ROUTE, openEntryPlugin); err != nil {
+		return err
+	}
+	registerEntryPlugins(entries)
+	return nil
+}
+
+// openEntryPlugin loads the Entry and StaticPaths functions of a compiled
+// entry file, either of which may be missing.
+func openEntryPlugin(pluginPath string) (map[string]interface{}, error) {
+	p, err := plugin.Open(pluginPath)
 	if err != nil {
-		utils.Logger.Sugar().Errorf("Failed to find Entry in %s: %v", filePath, err)
-		return
+		utils.Logger.Sugar().Errorf("Failed to load Entry plugin %s: %v", pluginPath, err)
+		return nil, err
 	}
-	entryHandler, ok := entrySymbol.(func(http.ResponseWriter, *http.Request, string) map[string]interface{})
-	if !ok {
-		utils.Logger.Sugar().Errorf("Entry in %s has an incorrect signature", filePath)
+
+	symbols := map[string]interface{}{}
+	for _, name := range []string{"Entry", "StaticPaths"} {
+		if symbol, err := p.Lookup(name); err == nil {
+			symbols[name] = symbol
+		}
+	}
+	return symbols, nil
+}
+
+// registerEntryPlugins maps the functions of every loaded entry plugin into
+// entries.
+func registerEntryPlugins(entries map[string]EntryRouteInfo) {
+	for _, sourcePath := range sortedPluginSources(entryPlugins) {
+		symbols := entryPlugins[sourcePath].symbols
+		registerEntryRoute(entries, sourcePath, symbols["Entry"], symbols["StaticPaths"])
+	}
+}
+
+// registerEntryRoute maps the Entry and StaticPaths functions of an entry file,
+// either of which may be nil, to the page route given by its path. The entry
+// is attached to the page by linkEntryRoutes.
+func registerEntryRoute(entries map[string]EntryRouteInfo, filePath string, entrySymbol interface{}, staticPathsSymbol interface{}) {
+	route := "/" + strings.TrimSuffix(strings.TrimPrefix(filePath, BASE_ENTRY_ROUTE+"/"), filepath.Ext(filePath))
+	if route == "/index" {
+		route = "/"
+	}
+
+	var entryHandler EntryRouteFn
+	if entrySymbol != nil {
+		handler, ok := entrySymbol.(func(http.ResponseWriter, *http.Request, string) map[string]interface{})
+		if !ok {
+			utils.Logger.Sugar().Errorf("Entry in %s has an incorrect signature", filePath)
+			return
+		}
+		entryHandler = handler
+	}
+
+	var staticPaths StaticPathsFn
+	if staticPathsSymbol != nil {
+		staticPathsFn, ok := staticPathsSymbol.(func() []StaticPath)
+		if !ok {
+			utils.Logger.Sugar().Errorf("StaticPaths in %s has an incorrect signature", filePath)
+			return
+		}
+		staticPaths = staticPathsFn
+	}
+
+	if entryHandler == nil && staticPaths == nil {
+		utils.Logger.Sugar().Errorf("Failed to find Entry or StaticPaths in %s", filePath)
 		return
 	}
 
-	if pageRoute, exists := RouteMap[route]; exists {
-		EntryRouteMap[route] = EntryRouteInfo{
-			FilePath:  filePath,
-			HandlerFn: entryHandler,
-			Route:     &pageRoute,
+	entries[route] = EntryRouteInfo{
+		FilePath:      filePath,
+		HandlerFn:     entryHandler,
+		StaticPathsFn: staticPaths,
+	}
+}
+
+// linkEntryRoutes attaches the entry files to their pages, leaving out those
+// without a matching page route.
+func linkEntryRoutes(pages map[string]RouteInfo, entries map[string]EntryRouteInfo) map[string]EntryRouteInfo {
+	linked := make(map[string]EntryRouteInfo, len(entries))
+	for route, entryInfo := range entries {
+		pageRoute, exists := pages[route]
+		if !exists {
+			utils.Logger.Sugar().Warnf("Entry route %s has no matching page route", route)
+			continue
 		}
-		utils.Logger.Sugar().Debugf("Mapped entry route: %s -> %s", route, filePath)
-	} else {
-		utils.Logger.Sugar().Warnf("Entry route %s has no matching page route", route)
+		entryInfo.Route = &pageRoute
+		linked[route] = entryInfo
+		utils.Logger.Sugar().Debugf("Mapped entry route: %s -> %s", route, entryInfo.FilePath)
 	}
+	return linked
+}
+
+// LookupEntryRoute returns the entry route of a page route.
+func LookupEntryRoute(route string) (EntryRouteInfo, bool) {
+	routesMu.RLock()
+	defer routesMu.RUnlock()
+
+	entryInfo, exists := EntryRouteMap[route]
+	return entryInfo, exists
+}
+
+// SetRouteParams exposes the dynamic segments matched for a page to its entry
+// handler through mux.Vars.
+func SetRouteParams(r *http.Request, params map[string]string) *http.Request {
+	if len(params) == 0 {
+		return r
+	}
+
+	vars := map[string]string{}
+	for name, value := range mux.Vars(r) {
+		vars[name] = value
+	}
+	for name, value := range params {
+		vars[name] = value
+	}
+	return mux.SetURLVars(r, vars)
 }
 
 // Watch API directory for changes and recompile as needed
@@ -159,12 +408,14 @@ func watchApiDirectory() {
 				if !ok {
 					return
 				}
-				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
+				watchCreatedDirectory(watcher, event)
+				if isRouteEvent(event, ".go") {
 					utils.Logger.Sugar().Infof("API file changed: %s", event.Name)
-					pluginPath, err := compileToPlugin(event.Name)
-					if err == nil {
-						generateApiRoute(pluginPath)
+					if err := reloadApiRoutes(event.Name); err != nil {
+						utils.Logger.Sugar().Errorf("Failed to reload API routes: %v", err)
+						continue
 					}
+					livereload.Broadcast(livereload.EventReload)
 				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
@@ -175,7 +426,7 @@ func watchApiDirectory() {
 		}
 	}()
 
-	err = watcher.Add(BASE_API_ROUTE)
+	err = watchTree(watcher, BASE_API_ROUTE)
 	if err != nil {
 		utils.Logger.Sugar().Fatalf("Failed to watch API directory: %v", err)
 	}
@@ -197,12 +448,14 @@ func watchEntryDirectory() {
 				if !ok {
 					return
 				}
-				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
+				watchCreatedDirectory(watcher, event)
+				if isRouteEvent(event, ".go") {
 					utils.Logger.Sugar().Infof("Entry file changed: %s", event.Name)
-					pluginPath, err := compileToPlugin(event.Name)
-					if err == nil {
-						generateEntryRoute(pluginPath)
+					if err := reloadEntryRoutes(event.Name); err != nil {
+						utils.Logger.Sugar().Errorf("Failed to reload Entry routes: %v", err)
+						continue
 					}
+					livereload.Broadcast(livereload.EventReload)
 				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
@@ -213,14 +466,14 @@ func watchEntryDirectory() {
 		}
 	}()
 
-	err = watcher.Add(BASE_ENTRY_ROUTE)
+	err = watchTree(watcher, BASE_ENTRY_ROUTE)
 	if err != nil {
 		utils.Logger.Sugar().Fatalf("Failed to watch Entry directory: %v", err)
 	}
 	select {}
 }
 
-func scanDirectory(directory string, baseRoute string) error {
+func scanDirectory(pages map[string]RouteInfo, directory string, baseRoute string) error {
 	return filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -235,9 +488,15 @@ func scanDirectory(directory string, baseRoute string) error {
 				route = strings.Replace(route, ".client", "", -1)
 			}
 
-			RouteMap[route] = RouteInfo{
-				PagePath: path,
-				IsSSG:    isSSG,
+			segments, dynamicNames := parseRoutePattern(route)
+
+			pages[route] = RouteInfo{
+				PagePath:     path,
+				IsSSG:        isSSG,
+				IsDynamic:    len(dynamicNames) > 0,
+				DynamicNames: dynamicNames,
+				Layouts:      findLayouts(directory, path),
+				segments:     segments,
 			}
 
 			utils.Logger.Sugar().Debugf("Mapped route: %s -> %s (SSR: %v)\n", route, path, isSSG)
@@ -246,6 +505,54 @@ func scanDirectory(directory string, baseRoute string) error {
 	})
 }
 
+// IsErrorRoute reports whether the route belongs to one of the error pages,
+// which are not served at their own URL.
+func IsErrorRoute(route string) bool {
+	return route == NOT_FOUND_ROUTE || route == SERVER_ERROR_ROUTE || route == ERROR_ROUTE
+}
+
+// ErrorPage returns the page to render for an error status code.
+func ErrorPage(status int) (RouteInfo, bool) {
+	route := ERROR_ROUTE
+	switch status {
+	case http.StatusNotFound:
+		route = NOT_FOUND_ROUTE
+	case http.StatusInternalServerError:
+		route = SERVER_ERROR_ROUTE
+	}
+
+	routesMu.RLock()
+	defer routesMu.RUnlock()
+
+	if routeInfo, exists := RouteMap[route]; exists {
+		return routeInfo, true
+	}
+	routeInfo, exists := RouteMap[ERROR_ROUTE]
+	return routeInfo, exists
+}
+
+// findLayouts returns the layout files that wrap a page, from the root of the
+// pages directory down to the page's own directory.
+func findLayouts(pagesDirectory string, pagePath string) []string {
+	root := filepath.Clean(pagesDirectory)
+	var layouts []string
+
+	for dir := filepath.Dir(pagePath); ; dir = filepath.Dir(dir) {
+		for _, name := range LAYOUT_FILES {
+			layoutPath := filepath.Join(dir, name)
+			if _, err := os.Stat(layoutPath); err == nil {
+				layouts = append([]string{layoutPath}, layouts...)
+				break
+			}
+		}
+		if dir == root || dir == "." || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	return layouts
+}
+
 func generateRouteFromFilePath(filePath string, baseRoute string) string {
 	route := strings.TrimPrefix(filePath, baseRoute)
 	route = strings.TrimPrefix(route, "/pages/")
@@ -277,16 +584,19 @@ func watchDirectory(directory string, baseRoute string) {
 				if !ok {
 					return
 				}
-				if event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create {
-					utils.Logger.Sugar().Infof("File changed: ", event.Name)
-					err := scanDirectory(directory, baseRoute)
+				watchCreatedDirectory(watcher, event)
+				if isRouteEvent(event) {
+					utils.Logger.Sugar().Infof("File changed: %s", event.Name)
+					react.InvalidateBundles(event.Name)
+					err := reloadPages(directory, baseRoute)
 					if err != nil {
-						utils.Logger.Sugar().Errorf("Error rescanning directory:", err)
+						utils.Logger.Sugar().Errorf("Error rescanning directory: %v", err)
 					}
 					err = react.BuildCSS()
 					if err != nil {
-						utils.Logger.Sugar().Fatalf("Error building CSS: %v", err)
+						utils.Logger.Sugar().Errorf("Error building CSS: %v", err)
 					}
+					livereload.Broadcast(livereload.EventReload)
 				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
@@ -297,39 +607,145 @@ func watchDirectory(directory string, baseRoute string) {
 		}
 	}()
 
-	err = watcher.Add(directory)
+	err = watchTree(watcher, directory)
 	if err != nil {
 		utils.Logger.Sugar().Fatal(err)
 	}
 	<-done
 }
 
-func InitializeRouting(baseRoute string, dev bool) {
-	err := scanDirectory(fmt.Sprintf("%s/pages/", baseRoute), baseRoute)
-	if err != nil {
-		utils.Logger.Sugar().Fatalf("Error scanning pages directory: %v", err)
+// reloadPages rebuilds RouteMap from the pages directory, dropping removed and
+// renamed pages, and relinks the entry routes to the new pages.
+func reloadPages(directory string, baseRoute string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	pages := map[string]RouteInfo{}
+	if err := scanDirectory(pages, directory, baseRoute); err != nil {
+		return err
 	}
 
-	err = scanApiDirectory()
-	if err != nil {
-		utils.Logger.Sugar().Fatalf("Error scanning API directory: %v", err)
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	RouteMap = pages
+	dynamicPages = indexPageRoutes(pages)
+	EntryRouteMap = linkEntryRoutes(pages, entryFiles)
+	return nil
+}
+
+// reloadApiRoutes recompiles the API files at or below the changed path, drops
+// the plugins of removed ones and rebuilds ApiRouteMap from the loaded plugins.
+func reloadApiRoutes(changedPath string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if err := reloadPlugins(apiPlugins, changedPath, openApiPlugin); err != nil {
+		return err
 	}
+	apiRoutes := map[string]map[string]ApiRouteInfo{}
+	registerApiPlugins(apiRoutes)
+
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	ApiRouteMap = apiRoutes
+	dynamicApiRoutes = indexApiRoutes(apiRoutes)
+	return nil
+}
 
-	err = scanEntryDirectory()
-	if err != nil {
-		utils.Logger.Sugar().Fatalf("Error scanning Entry directory: %v", err)
+// reloadEntryRoutes recompiles the entry files at or below the changed path,
+// drops the plugins of removed ones and rebuilds the entry routes from the
+// loaded plugins.
+func reloadEntryRoutes(changedPath string) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if err := reloadPlugins(entryPlugins, changedPath, openEntryPlugin); err != nil {
+		return err
+	}
+	entries := map[string]EntryRouteInfo{}
+	registerEntryPlugins(entries)
+
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	entryFiles = entries
+	EntryRouteMap = linkEntryRoutes(RouteMap, entries)
+	return nil
+}
+
+// InitializeApiRouting only loads the API routes and middleware, for serving
+// them next to a prebuilt static site.
+func InitializeApiRouting() {
+	if usesCompiledRoutes() {
+		if err := loadCompiledRoutes(false); err != nil {
+			utils.Logger.Sugar().Fatalf("Error loading compiled routes: %v", err)
+		}
+	} else {
+		if err := scanApiDirectory(ApiRouteMap); err != nil {
+			utils.Logger.Sugar().Fatalf("Error scanning API directory: %v", err)
+		}
+		if err := loadMiddleware(); err != nil {
+			utils.Logger.Sugar().Fatalf("Error loading middleware: %v", err)
+		}
 	}
+	indexRoutes()
+
+	utils.Logger.Sugar().Debugf("Initial API routes:", ApiRouteMap)
+}
 
-	err = loadMiddleware()
+// InitializeMiddleware only loads the middleware, for serving a prebuilt
+// static site without its API routes.
+func InitializeMiddleware() {
+	var err error
+	if usesCompiledRoutes() {
+		err = loadCompiledMiddleware()
+	} else {
+		err = loadMiddleware()
+	}
 	if err != nil {
 		utils.Logger.Sugar().Fatalf("Error loading middleware: %v", err)
 	}
+}
+
+func InitializeRouting(baseRoute string, dev bool) {
+	if utils.HasEmbeddedAssets() {
+		if err := loadPageManifest(baseRoute); err != nil {
+			utils.Logger.Sugar().Fatalf("Error loading pages: %v", err)
+		}
+	} else if err := ScanPages(baseRoute); err != nil {
+		utils.Logger.Sugar().Fatalf("Error scanning pages directory: %v", err)
+	}
 
-	if dev {
+	if usesCompiledRoutes() {
+		if err := loadCompiledRoutes(true); err != nil {
+			utils.Logger.Sugar().Fatalf("Error loading compiled routes: %v", err)
+		}
+	} else {
+		err := scanApiDirectory(ApiRouteMap)
+		if err != nil {
+			utils.Logger.Sugar().Fatalf("Error scanning API directory: %v", err)
+		}
+
+		err = scanEntryDirectory(entryFiles)
+		if err != nil {
+			utils.Logger.Sugar().Fatalf("Error scanning Entry directory: %v", err)
+		}
+		EntryRouteMap = linkEntryRoutes(RouteMap, entryFiles)
+
+		err = loadMiddleware()
+		if err != nil {
+			utils.Logger.Sugar().Fatalf("Error loading middleware: %v", err)
+		}
+	}
+	indexRoutes()
+
+	if dev && !utils.HasEmbeddedAssets() {
 		go watchDirectory(fmt.Sprintf("%s/pages/", baseRoute), baseRoute)
-		go watchApiDirectory()
-		go watchEntryDirectory()
-		go watchMiddlewareDirectory()
+		// Compiled routes are fixed at build time, only plugins can be reloaded
+		if !usesCompiledRoutes() {
+			go watchApiDirectory()
+			go watchEntryDirectory()
+			go watchMiddlewareDirectory()
+		}
 	}
 
 	utils.Logger.Sugar().Debugf("Initial routes:", RouteMap)
@@ -1,16 +1,55 @@
 package router
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/bendigiorgio/ikou/internal/app/utils"
+	"github.com/fsnotify/fsnotify"
 )
 
+// pluginHashLength is the number of hex digits of the source hash in the file
+// name of a compiled plugin.
+const pluginHashLength = 12
+
+// pluginPathFor returns the path a route file is compiled to, named after a
+// hash of its contents, e.g. get-0123456789ab.so for get.go. plugin.Open
+// caches plugins by path, including failed loads, so every edit has to be
+// compiled to a new file for its code to be loaded.
+func pluginPathFor(filePath string) (string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf("%s-%s.so", strings.TrimSuffix(filePath, ".go"), hex.EncodeToString(sum[:])[:pluginHashLength]), nil
+}
+
+// pluginSource returns the route file a plugin was compiled from, or false
+// when the file name does not carry a source hash.
+func pluginSource(pluginPath string) (string, bool) {
+	name := strings.TrimSuffix(pluginPath, ".so")
+	index := strings.LastIndex(name, "-")
+	if index < 0 || len(name)-index-1 != pluginHashLength {
+		return "", false
+	}
+	return name[:index] + ".go", true
+}
+
 func compileToPlugin(filePath string) (string, error) {
-	outputPath := strings.TrimSuffix(filePath, ".go") + ".so"
+	outputPath, err := pluginPathFor(filePath)
+	if err != nil {
+		utils.Logger.Sugar().Errorf("Failed to read %s: %v", filePath, err)
+		return "", err
+	}
 	cmd := exec.Command("go", "build", "-buildmode=plugin", "-o", outputPath, filePath)
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
 		utils.Logger.Sugar().Errorf("Failed to compile %s to plugin: %v", filePath, err)
 		return "", err
@@ -18,3 +57,154 @@ func compileToPlugin(filePath string) (string, error) {
 	utils.Logger.Sugar().Infof("Compiled %s to %s", filePath, outputPath)
 	return outputPath, nil
 }
+
+// isStalePlugin reports whether the file is a plugin other than the current
+// build of its route file, because the file has since been edited, removed or
+// renamed.
+func isStalePlugin(path string, info os.FileInfo) bool {
+	if info.IsDir() || filepath.Ext(path) != ".so" {
+		return false
+	}
+	sourcePath, ok := pluginSource(path)
+	if !ok {
+		return true
+	}
+	current, err := pluginPathFor(sourcePath)
+	return err != nil || current != path
+}
+
+func removePlugin(pluginPath string) {
+	if err := os.Remove(pluginPath); err != nil {
+		if !os.IsNotExist(err) {
+			utils.Logger.Sugar().Errorf("Failed to remove stale plugin %s: %v", pluginPath, err)
+		}
+		return
+	}
+	utils.Logger.Sugar().Infof("Removed stale plugin %s", pluginPath)
+}
+
+// routePlugin is the plugin loaded for a route file and the symbols looked up
+// in it.
+type routePlugin struct {
+	path    string
+	symbols map[string]interface{}
+}
+
+// loadPlugins compiles and loads every Go file at or below path into plugins,
+// keyed by source path, and deletes the plugin each new build replaces. A file
+// that fails to compile or load keeps the plugin it had before, and the .so
+// files of edited and removed sources are deleted.
+func loadPlugins(plugins map[string]routePlugin, path string, open func(string) (map[string]interface{}, error)) error {
+	return filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if isStalePlugin(filePath, info) {
+			removePlugin(filePath)
+			return nil
+		}
+		if info.IsDir() || !strings.HasSuffix(filePath, ".go") {
+			return nil
+		}
+
+		pluginPath, err := compileToPlugin(filePath)
+		if err != nil {
+			return nil
+		}
+		symbols, err := open(pluginPath)
+		if err != nil {
+			return nil
+		}
+		if previous, exists := plugins[filePath]; exists && previous.path != pluginPath {
+			removePlugin(previous.path)
+		}
+		plugins[filePath] = routePlugin{path: pluginPath, symbols: symbols}
+		return nil
+	})
+}
+
+// reloadPlugins brings plugins in line with a changed file or directory: the
+// plugins of sources at or below changedPath that no longer exist are dropped,
+// and the remaining sources there are recompiled. Other plugins are untouched.
+func reloadPlugins(plugins map[string]routePlugin, changedPath string, open func(string) (map[string]interface{}, error)) error {
+	changedPath = filepath.Clean(changedPath)
+
+	for sourcePath, loaded := range plugins {
+		if sourcePath != changedPath && !strings.HasPrefix(sourcePath, changedPath+string(filepath.Separator)) {
+			continue
+		}
+		if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
+			delete(plugins, sourcePath)
+			removePlugin(loaded.path)
+		}
+	}
+
+	if _, err := os.Stat(changedPath); os.IsNotExist(err) {
+		return nil
+	}
+	return loadPlugins(plugins, changedPath, open)
+}
+
+// sortedPluginSources returns the source paths of plugins in lexical order.
+func sortedPluginSources(plugins map[string]routePlugin) []string {
+	paths := make([]string, 0, len(plugins))
+	for sourcePath := range plugins {
+		paths = append(paths, sourcePath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// watchTree adds the directory and every directory below it to the watcher, as
+// fsnotify does not watch recursively.
+func watchTree(watcher *fsnotify.Watcher, directory string) error {
+	return filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// isRouteEvent reports whether a watcher event can change the routes: a write,
+// creation, removal or rename of a file with one of the extensions, or of a
+// directory, which can hold any number of route files.
+func isRouteEvent(event fsnotify.Event, extensions ...string) bool {
+	if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+		return false
+	}
+
+	ext := filepath.Ext(event.Name)
+	if len(extensions) == 0 {
+		return true
+	}
+	for _, extension := range extensions {
+		if ext == extension {
+			return true
+		}
+	}
+
+	// A removed directory cannot be told apart from a file anymore, assume
+	// anything without an extension was one
+	if event.Has(fsnotify.Create) {
+		info, err := os.Stat(event.Name)
+		return err == nil && info.IsDir()
+	}
+	return !event.Has(fsnotify.Write) && ext == ""
+}
+
+// watchCreatedDirectory starts watching a directory created or moved into a
+// watched directory.
+func watchCreatedDirectory(watcher *fsnotify.Watcher, event fsnotify.Event) {
+	if !event.Has(fsnotify.Create) {
+		return
+	}
+	if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+		if err := watchTree(watcher, event.Name); err != nil {
+			utils.Logger.Sugar().Errorf("Failed to watch %s: %v", event.Name, err)
+		}
+	}
+}
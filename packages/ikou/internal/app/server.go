@@ -1,10 +1,12 @@
 package app
 
 import (
+	"io/fs"
 	"net/http"
 	"path"
 	"strconv"
 
+	"github.com/bendigiorgio/ikou/internal/app/livereload"
 	"github.com/bendigiorgio/ikou/internal/app/react"
 	"github.com/bendigiorgio/ikou/internal/app/router"
 	"github.com/bendigiorgio/ikou/internal/app/utils"
@@ -29,11 +31,29 @@ func StartServer(devMode bool) {
 	}
 
 	router.InitializeRouting(srcPath, devMode)
+	go react.ReportPoolStats(react.POOL_STATS_INTERVAL)
 
 	r := mux.NewRouter()
 
-	staticDir := http.FileServer(http.Dir(staticPath))
+	var staticFiles http.FileSystem = http.Dir(staticPath)
+	if utils.HasEmbeddedAssets() {
+		if err := react.LoadPrebuiltBundles(); err != nil {
+			utils.Logger.Sugar().Fatalf("Error loading prebuilt bundles: %v", err)
+		}
+		publicFiles, err := fs.Sub(utils.EmbeddedAssets, utils.EMBEDDED_PUBLIC_DIR)
+		if err != nil {
+			utils.Logger.Sugar().Fatalf("Error loading embedded public files: %v", err)
+		}
+		staticFiles = http.FS(publicFiles)
+	}
+
+	staticDir := http.FileServer(staticFiles)
 	r.PathPrefix("/public/").Handler(http.StripPrefix("/public/", staticDir))
+	if devMode {
+		r.HandleFunc(livereload.EVENTS_PATH, livereload.Handler)
+		go livereload.WatchStylesheets(staticPath)
+	}
+	r.PathPrefix(react.CLIENT_ASSET_PREFIX).HandlerFunc(react.ServeClientAsset)
 
 	r.HandleFunc("/{route:.*}", func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
@@ -45,52 +65,80 @@ func StartServer(devMode bool) {
 			route = "/" + route
 		}
 
-		routeInfo, exists := router.RouteMap[route]
-		apiRouteInfo, apiExists := router.ApiRouteMap[route]
+		// API routes go first so a catch-all page cannot shadow them
+		if router.HandleApiRoute(w, r, route) {
+			return
+		}
+
+		routeKey, routeInfo, params, exists := router.MatchRoute(route)
 
-		if exists {
+		// Error pages are only rendered with their own status code
+		if exists && !router.IsErrorRoute(routeKey) {
 			initialProps := react.PageProps{
 				PageRoute: route,
+				Params:    params,
+				RequestID: r.Header.Get(router.REQUEST_ID_HEADER),
 			}
 
-			entryInfo, entryExists := router.EntryRouteMap[route]
-			if entryExists {
+			entryInfo, entryExists := router.LookupEntryRoute(routeKey)
+			if entryExists && entryInfo.HandlerFn != nil {
+				r = router.SetRouteParams(r, params)
 				initialProps.Data = entryInfo.HandlerFn(w, r, entryInfo.FilePath)
 			}
 
-			pageData, err := react.RenderPage(
+			err := react.StreamPage(
+				w,
+				http.StatusOK,
 				routeInfo.IsSSG,
 				initialProps,
 				routeInfo.PagePath,
+				routeInfo.Layouts,
 			)
 			if err != nil {
-				utils.Logger.Error("Page not found", zap.Error(err))
-				http.Error(w, "Page not found", http.StatusNotFound)
-				return
-			}
-			err = pageData.Tmpl.Execute(w, pageData)
-			if err != nil {
-				utils.Logger.Error("Error executing template", zap.Error(err))
-			}
-			return
-		}
-
-		if apiExists {
-			// check if the request method is allowed
-			if r.Method != apiRouteInfo.Method {
-				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-				return
+				utils.Logger.Error("Error rendering page", zap.String("route", route), zap.Error(err))
+				if devMode {
+					react.WriteErrorOverlay(w, err)
+					return
+				}
+				renderErrorPage(w, r, route, http.StatusInternalServerError, err)
 			}
-
-			// if it exists call the api handler function
-			apiRouteInfo.HandlerFn(w, r, apiRouteInfo.FilePath)
 			return
 		}
 
 		utils.Logger.Error("Page not found", zap.String("route", route))
-		http.Error(w, "Page not found", http.StatusNotFound)
+		renderErrorPage(w, r, route, http.StatusNotFound, nil)
 	})
 	portString := ":" + port
-	utils.Logger.Sugar().Fatal(http.ListenAndServe(portString, r))
+	utils.Logger.Sugar().Fatal(http.ListenAndServe(portString, router.ApplyMiddleware(r)))
+
+}
+
+// renderErrorPage responds with the React error page for the status code, i.e.
+// pages/404.page.tsx or pages/500.page.tsx, falling back to pages/_error.page.tsx
+// and finally to a plain text response.
+func renderErrorPage(w http.ResponseWriter, r *http.Request, route string, status int, renderErr error) {
+	routeInfo, exists := router.ErrorPage(status)
+	if !exists {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	initialProps := react.PageProps{
+		PageRoute:  route,
+		StatusCode: status,
+		RequestID:  r.Header.Get(router.REQUEST_ID_HEADER),
+	}
+	// Render errors can contain file paths and V8 internals, only show them in dev
+	if renderErr != nil {
+		initialProps.Error = http.StatusText(status)
+		if utils.IsDevMode() {
+			initialProps.Error = renderErr.Error()
+		}
+	}
 
+	err := react.StreamPage(w, status, routeInfo.IsSSG, initialProps, routeInfo.PagePath, routeInfo.Layouts)
+	if err != nil {
+		utils.Logger.Error("Error rendering error page", zap.Int("status", status), zap.Error(err))
+		http.Error(w, http.StatusText(status), status)
+	}
 }
@@ -0,0 +1,93 @@
+package serverbuild
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/bendigiorgio/ikou/internal/app/react"
+	"github.com/bendigiorgio/ikou/internal/app/router"
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+)
+
+// ASSETS_DIR is the directory below SERVER_BUILD_DIR embedded into the server
+// binary as utils.EmbeddedAssets.
+const ASSETS_DIR = "assets"
+
+// generateAssets builds everything the server needs from the frontend source
+// tree into ASSETS_DIR: the stylesheet and public directory, the bundles of
+// every page, the page routes, the document template and the config.
+func generateAssets() error {
+	assetsDir := filepath.Join(SERVER_BUILD_DIR, ASSETS_DIR)
+
+	if err := react.BuildCSS(); err != nil {
+		return fmt.Errorf("failed to build CSS: %w", err)
+	}
+
+	srcPath := utils.GlobalConfig.BasePath
+	if utils.GlobalConfig.UseSrc {
+		srcPath = path.Join(srcPath, "src")
+	}
+	if err := router.ScanPages(srcPath); err != nil {
+		return fmt.Errorf("failed to scan pages: %w", err)
+	}
+
+	routes := make([]string, 0, len(router.RouteMap))
+	for route := range router.RouteMap {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	var errs []error
+	for _, route := range routes {
+		routeInfo := router.RouteMap[route]
+		if err := react.PrebuildPage(routeInfo.IsSSG, routeInfo.PagePath, routeInfo.Layouts); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", routeInfo.PagePath, err))
+			continue
+		}
+		utils.Logger.Sugar().Debugf("Prebuilt page %s", routeInfo.PagePath)
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if err := react.WriteBundleManifest(assetsDir); err != nil {
+		return err
+	}
+	if err := router.WritePageManifest(srcPath, assetsDir); err != nil {
+		return err
+	}
+
+	publicDir := filepath.Join(assetsDir, utils.EMBEDDED_PUBLIC_DIR)
+	if err := os.MkdirAll(publicDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", publicDir, err)
+	}
+	if _, err := os.Stat(utils.GlobalConfig.StaticPath); err == nil {
+		if err := utils.CopyDir(utils.GlobalConfig.StaticPath, publicDir); err != nil {
+			return fmt.Errorf("failed to copy static files: %w", err)
+		}
+	}
+
+	documentPath := path.Join(utils.GlobalConfig.BasePath, react.DOCUMENT_TEMPLATE)
+	if document, err := os.ReadFile(documentPath); err == nil {
+		if err := os.WriteFile(filepath.Join(assetsDir, react.DOCUMENT_TEMPLATE), document, 0644); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", documentPath, err)
+		}
+	}
+
+	// The config the server was built with, used when none is found at runtime
+	config, err := json.MarshalIndent(utils.GlobalConfig, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(assetsDir, utils.EMBEDDED_CONFIG), config, 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	utils.Logger.Sugar().Infof("Prebuilt %d pages into %s", len(routes), assetsDir)
+	return nil
+}
@@ -0,0 +1,366 @@
+package serverbuild
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/bendigiorgio/ikou/internal/app/router"
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+)
+
+// SERVER_BUILD_DIR is where the route packages and the main package of the
+// server binary are generated, relative to the working directory.
+const SERVER_BUILD_DIR = "generated/server"
+
+// SERVER_MODULE_PATH is the module path of the generated server. It sits below
+// the ikou module path, which lets the server import ikou's internal packages
+// from any project.
+const SERVER_MODULE_PATH = "github.com/bendigiorgio/ikou/generated/server"
+
+// ROUTE_SYMBOLS are the functions a route file may export, by route directory.
+var ROUTE_SYMBOLS = map[string][]string{
+	router.BASE_API_ROUTE:        {"Handler"},
+	router.BASE_ENTRY_ROUTE:      {"Entry", "StaticPaths"},
+	router.BASE_MIDDLEWARE_ROUTE: {"Middleware"},
+}
+
+// ROUTE_SIGNATURES are the types of the route functions with a single accepted
+// signature. The generated main asserts them, so a wrong signature fails the
+// build instead of leaving the route out at startup. Handler accepts both the
+// api package and the legacy signature, so it is checked when registered.
+var ROUTE_SIGNATURES = map[string]string{
+	"Entry":       "func(http.ResponseWriter, *http.Request, string) map[string]interface{}",
+	"StaticPaths": "func() []router.StaticPath",
+	"Middleware":  "func(http.Handler) http.Handler",
+}
+
+// signatureCheck asserts the type of one route function in the generated main.
+type signatureCheck struct {
+	Type   string
+	Symbol string
+}
+
+// routePackage is a route file copied into its own package of the server.
+type routePackage struct {
+	FilePath string
+	Name     string
+	Symbols  []string
+}
+
+var mainTemplate = template.Must(template.New("main").Parse(`// Code generated by ikou build --server. DO NOT EDIT.
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+{{- if .UsesHTTP}}
+	"net/http"
+{{- end}}
+	"os"
+
+	"github.com/bendigiorgio/ikou/internal/app/router"
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+	"github.com/bendigiorgio/ikou/internal/cmd"
+{{- range .Routes}}
+	"{{$.ModulePath}}/routes/{{.Name}}"
+{{- end}}
+)
+
+//go:embed all:{{.AssetsDir}}
+var embeddedAssets embed.FS
+
+func main() {
+	assets, err := fs.Sub(embeddedAssets, "{{.AssetsDir}}")
+	if err != nil {
+		panic(err)
+	}
+	utils.UseEmbeddedAssets(assets)
+
+	router.RegisterCompiledRoutes([]router.CompiledRoute{
+{{- range .Routes}}
+		{
+			FilePath: "{{.FilePath}}",
+			Symbols: map[string]interface{}{
+{{- $name := .Name}}
+{{- range .Symbols}}
+				"{{.}}": {{$name}}.{{.}},
+{{- end}}
+			},
+		},
+{{- end}}
+	})
+
+	if err := cmd.RunServerBinary(os.Args); err != nil {
+		panic(err)
+	}
+}
+{{- if .Checks}}
+
+// Route functions must have the signatures the router expects
+var (
+{{- range .Checks}}
+	_ {{.Type}} = {{.Symbol}}
+{{- end}}
+)
+{{- end}}
+`))
+
+// BuildServer generates a main package that registers every API, entry and
+// middleware file as a normal Go package instead of a plugin and embeds the
+// prebuilt frontend, and builds it into a single server binary at outputPath
+// that runs without the source tree.
+func BuildServer(outputPath string) error {
+	routes, err := generateServer()
+	if err != nil {
+		return err
+	}
+	utils.Logger.Sugar().Infof("Generated server with %d route files in %s", len(routes), SERVER_BUILD_DIR)
+
+	if err := generateAssets(); err != nil {
+		return err
+	}
+
+	return buildBinary(outputPath)
+}
+
+// generateServer replaces SERVER_BUILD_DIR with a package per route file and
+// the main package registering them.
+func generateServer() ([]routePackage, error) {
+	if err := os.RemoveAll(SERVER_BUILD_DIR); err != nil {
+		return nil, fmt.Errorf("failed to clean %s: %w", SERVER_BUILD_DIR, err)
+	}
+
+	var routes []routePackage
+	usedNames := map[string]bool{}
+	for _, baseDir := range []string{router.BASE_API_ROUTE, router.BASE_ENTRY_ROUTE, router.BASE_MIDDLEWARE_ROUTE} {
+		files, err := routeFiles(baseDir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, filePath := range files {
+			name := packageName(filePath, usedNames)
+			route, err := generateRoutePackage(filePath, name, ROUTE_SYMBOLS[baseDir])
+			if err != nil {
+				return nil, err
+			}
+			if len(route.Symbols) == 0 {
+				utils.Logger.Sugar().Warnf("Skipping %s, it exports none of %s", filePath, strings.Join(ROUTE_SYMBOLS[baseDir], ", "))
+				continue
+			}
+			usedNames[name] = true
+			routes = append(routes, route)
+		}
+	}
+
+	var checks []signatureCheck
+	usesHTTP := false
+	for _, route := range routes {
+		for _, symbol := range route.Symbols {
+			if signature, exists := ROUTE_SIGNATURES[symbol]; exists {
+				checks = append(checks, signatureCheck{Type: signature, Symbol: route.Name + "." + symbol})
+				usesHTTP = usesHTTP || strings.Contains(signature, "http.")
+			}
+		}
+	}
+
+	var mainFile bytes.Buffer
+	err := mainTemplate.Execute(&mainFile, map[string]interface{}{
+		"ModulePath": SERVER_MODULE_PATH,
+		"AssetsDir":  ASSETS_DIR,
+		"Routes":     routes,
+		"Checks":     checks,
+		"UsesHTTP":   usesHTTP,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate server main: %w", err)
+	}
+	source, err := format.Source(mainFile.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format server main: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(SERVER_BUILD_DIR, "main.go"), source, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write server main: %w", err)
+	}
+
+	if err := generateModule(); err != nil {
+		return nil, err
+	}
+
+	return routes, nil
+}
+
+// generateModule makes SERVER_BUILD_DIR a module of its own and puts it in a
+// workspace with the module of the working directory. Route files can then
+// import the packages of that module, and the server is built against the
+// ikou version it requires.
+func generateModule() error {
+	output, err := goCommand([]string{"GOWORK=off"}, "list", "-m", "-f", "{{.Dir}}\t{{.GoVersion}}")
+	moduleDir, goVersion, found := strings.Cut(strings.TrimSpace(output), "\t")
+	if err != nil || !found || moduleDir == "" {
+		return fmt.Errorf("ikou build --server must run inside a Go module requiring ikou: %w\n%s", err, output)
+	}
+
+	buildDir, err := filepath.Abs(SERVER_BUILD_DIR)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", SERVER_BUILD_DIR, err)
+	}
+	relModuleDir, err := filepath.Rel(buildDir, moduleDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", moduleDir, err)
+	}
+
+	goMod := fmt.Sprintf("module %s\n\ngo %s\n", SERVER_MODULE_PATH, goVersion)
+	if err := os.WriteFile(filepath.Join(SERVER_BUILD_DIR, "go.mod"), []byte(goMod), 0644); err != nil {
+		return fmt.Errorf("failed to write server go.mod: %w", err)
+	}
+	goWork := fmt.Sprintf("go %s\n\nuse (\n\t.\n\t%s\n)\n", goVersion, filepath.ToSlash(relModuleDir))
+	if err := os.WriteFile(filepath.Join(SERVER_BUILD_DIR, "go.work"), []byte(goWork), 0644); err != nil {
+		return fmt.Errorf("failed to write server go.work: %w", err)
+	}
+	return nil
+}
+
+// routeFiles lists the .go files under dir, sorted so the generated server is
+// the same for the same routes.
+func routeFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(path) == ".go" && !strings.HasSuffix(path, "_test.go") {
+			files = append(files, filepath.ToSlash(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// generateRoutePackage copies a route file into its own package, renamed from
+// main so it can be imported, and reports which of symbols it declares.
+func generateRoutePackage(filePath string, name string, symbols []string) (routePackage, error) {
+	route := routePackage{FilePath: filePath, Name: name}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
+	if err != nil {
+		return route, fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+
+	declared := map[string]bool{}
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+			declared[fn.Name.Name] = true
+		}
+	}
+	for _, symbol := range symbols {
+		if declared[symbol] {
+			route.Symbols = append(route.Symbols, symbol)
+		}
+	}
+	if len(route.Symbols) == 0 {
+		return route, nil
+	}
+
+	file.Name.Name = name
+	var source bytes.Buffer
+	if err := format.Node(&source, fset, file); err != nil {
+		return route, fmt.Errorf("failed to generate %s: %w", filePath, err)
+	}
+
+	packageDir := filepath.Join(SERVER_BUILD_DIR, "routes", name)
+	if err := os.MkdirAll(packageDir, 0755); err != nil {
+		return route, fmt.Errorf("failed to create %s: %w", packageDir, err)
+	}
+	if err := os.WriteFile(filepath.Join(packageDir, filepath.Base(filePath)), source.Bytes(), 0644); err != nil {
+		return route, fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+
+	return route, nil
+}
+
+// packageName turns a route file path into a unique Go identifier, e.g.
+// routes/api/users/[id]/get.go becomes api_users_id_get.
+func packageName(filePath string, usedNames map[string]bool) string {
+	path := strings.TrimSuffix(strings.TrimPrefix(filePath, "routes/"), ".go")
+
+	var name strings.Builder
+	lastUnderscore := true
+	for _, r := range strings.ToLower(path) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			name.WriteRune(r)
+			lastUnderscore = false
+		} else if !lastUnderscore {
+			name.WriteRune('_')
+			lastUnderscore = true
+		}
+	}
+
+	base := strings.TrimSuffix(name.String(), "_")
+	if base == "" || !unicode.IsLetter(rune(base[0])) {
+		base = "route_" + base
+	}
+
+	unique := base
+	for i := 2; usedNames[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", base, i)
+	}
+	return unique
+}
+
+// buildBinary builds the generated main package. v8go needs cgo, so a fully
+// static binary depends on the C toolchain providing static libraries; when it
+// does not, the binary is linked dynamically against libc instead.
+func buildBinary(outputPath string) error {
+	goWork, err := filepath.Abs(filepath.Join(SERVER_BUILD_DIR, "go.work"))
+	if err != nil {
+		return fmt.Errorf("failed to resolve the server workspace: %w", err)
+	}
+	env := []string{"GOWORK=" + goWork}
+
+	pkg := "./" + SERVER_BUILD_DIR
+	static := []string{"build", "-ldflags", "-linkmode external -extldflags -static", "-o", outputPath, pkg}
+	output, err := goCommand(env, static...)
+	if err == nil {
+		utils.Logger.Sugar().Infof("Built static server binary %s", outputPath)
+		return nil
+	}
+	utils.Logger.Sugar().Warnf("Static linking failed, building a dynamically linked binary instead:\n%s", output)
+
+	output, err = goCommand(env, "build", "-o", outputPath, pkg)
+	if err != nil {
+		return fmt.Errorf("failed to build server binary: %w\n%s", err, output)
+	}
+	utils.Logger.Sugar().Infof("Built server binary %s", outputPath)
+	return nil
+}
+
+// goCommand runs the go command with cgo enabled and env added to the
+// environment.
+func goCommand(env []string, args ...string) (string, error) {
+	cmd := exec.Command("go", args...)
+	cmd.Env = append(append(os.Environ(), "CGO_ENABLED=1"), env...)
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
@@ -1,10 +1,20 @@
 package ssg
 
 import (
+	"errors"
+	"fmt"
 	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/bendigiorgio/ikou/internal/app/react"
 	"github.com/bendigiorgio/ikou/internal/app/router"
@@ -12,7 +22,28 @@ import (
 	"go.uber.org/zap"
 )
 
-func GenerateStaticSite() error {
+// pageJob is a single HTML file the static site generator has to render.
+type pageJob struct {
+	route      string
+	pagePath   string
+	routeInfo  router.RouteInfo
+	entryInfo  *router.EntryRouteInfo
+	params     map[string]string
+	props      map[string]interface{}
+	statusCode int
+}
+
+// pageTiming records how long a page took to render and write.
+type pageTiming struct {
+	pagePath string
+	duration time.Duration
+	err      error
+}
+
+// GenerateStaticSite renders every page into the output directory using up to
+// concurrency workers. Rendering carries on past failing pages and all errors
+// are returned together once the build finishes.
+func GenerateStaticSite(concurrency int) error {
 	outputDir := utils.GlobalConfig.OutPath
 
 	basePath := utils.GlobalConfig.BasePath
@@ -24,52 +55,267 @@ func GenerateStaticSite() error {
 		srcPath = path.Join(basePath, "src")
 	}
 
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
 	router.InitializeRouting(srcPath, false)
+	react.DisablePoolWarming()
 
 	if err := utils.CopyDir(staticPath, filepath.Join(outputDir, "public")); err != nil {
 		utils.Logger.Error("Error copying static files", zap.Error(err))
 		return err
 	}
 
+	jobs, errs := collectPageJobs()
+
+	// Each page's render contexts are closed after its last job, as pages
+	// are not rendered again during the build
+	var remainingMu sync.Mutex
+	remaining := map[string]int{}
+	for _, job := range jobs {
+		remaining[job.routeInfo.PagePath]++
+	}
+
+	start := time.Now()
+	timings := make([]pageTiming, len(jobs))
+	queue := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range queue {
+				jobStart := time.Now()
+				err := runPageJob(outputDir, jobs[index])
+				timings[index] = pageTiming{
+					pagePath: jobs[index].pagePath,
+					duration: time.Since(jobStart),
+					err:      err,
+				}
+
+				routeInfo := jobs[index].routeInfo
+				remainingMu.Lock()
+				remaining[routeInfo.PagePath]--
+				done := remaining[routeInfo.PagePath] == 0
+				remainingMu.Unlock()
+				if done {
+					react.ClosePage(routeInfo.PagePath, routeInfo.Layouts)
+				}
+			}
+		}()
+	}
+	for index := range jobs {
+		queue <- index
+	}
+	close(queue)
+	wg.Wait()
+
+	for _, timing := range timings {
+		if timing.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", timing.pagePath, timing.err))
+		}
+	}
+
+	if err := react.WriteClientAssets(outputDir); err != nil {
+		utils.Logger.Error("Error writing client assets", zap.Error(err))
+		errs = append(errs, err)
+	}
+
+	logTimingSummary(timings, time.Since(start), concurrency)
+
+	return errors.Join(errs...)
+}
+
+// collectPageJobs expands the route map into one job per output file, calling
+// StaticPaths for dynamic pages. Jobs are sorted by path so builds are stable.
+func collectPageJobs() ([]pageJob, []error) {
+	var jobs []pageJob
+	var errs []error
+
+	// Error pages are only emitted as 404.html, which `ikou serve` falls back to
+	if routeInfo, exists := router.ErrorPage(http.StatusNotFound); exists {
+		jobs = append(jobs, pageJob{
+			route:      router.NOT_FOUND_ROUTE,
+			pagePath:   router.NOT_FOUND_ROUTE,
+			routeInfo:  routeInfo,
+			statusCode: http.StatusNotFound,
+		})
+	}
+
 	for route, routeInfo := range router.RouteMap {
-		initialProps := react.PageProps{
-			PageRoute: route,
+		if router.IsErrorRoute(route) {
+			continue
 		}
 
-		pageData, err := react.RenderPage(routeInfo.IsSSG, initialProps, routeInfo.PagePath)
-		if err != nil {
-			utils.Logger.Error("Error rendering page", zap.String("route", route), zap.Error(err))
-			return err
+		var entry *router.EntryRouteInfo
+		if entryInfo, entryExists := router.EntryRouteMap[route]; entryExists {
+			entry = &entryInfo
 		}
 
-		outputPath := filepath.Join(outputDir, route)
-		if route == "/" || route == "." {
-			outputPath = filepath.Join(outputDir, "index.html")
-		} else {
-			if err := os.MkdirAll(filepath.Dir(outputPath), fs.ModePerm); err != nil {
-				utils.Logger.Error("Error creating directories", zap.String("path", outputPath), zap.Error(err))
-				return err
-			}
-			if filepath.Ext(outputPath) == "" {
-				outputPath += ".html"
+		if !routeInfo.IsDynamic {
+			jobs = append(jobs, pageJob{
+				route:     route,
+				pagePath:  route,
+				routeInfo: routeInfo,
+				entryInfo: entry,
+			})
+			continue
+		}
+
+		// Dynamic pages are rendered once for every path returned by StaticPaths
+		if entry == nil || entry.StaticPathsFn == nil {
+			utils.Logger.Warn("Skipping dynamic page without StaticPaths", zap.String("route", route))
+			continue
+		}
+
+		for _, staticPath := range entry.StaticPathsFn() {
+			pagePath, err := router.BuildPath(route, staticPath.Params)
+			if err != nil {
+				utils.Logger.Error("Error building static path", zap.String("route", route), zap.Error(err))
+				errs = append(errs, fmt.Errorf("%s: %w", route, err))
+				continue
 			}
+
+			jobs = append(jobs, pageJob{
+				route:     route,
+				pagePath:  pagePath,
+				routeInfo: routeInfo,
+				entryInfo: entry,
+				params:    staticPath.Params,
+				props:     staticPath.Props,
+			})
 		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].pagePath < jobs[j].pagePath
+	})
 
-		file, err := os.Create(outputPath)
+	return jobs, errs
+}
+
+func runPageJob(outputDir string, job pageJob) error {
+	initialProps := react.PageProps{
+		PageRoute:  job.pagePath,
+		Params:     job.params,
+		Data:       job.props,
+		StatusCode: job.statusCode,
+	}
+
+	if job.entryInfo != nil && job.entryInfo.HandlerFn != nil {
+		data, err := runEntryHandler(*job.entryInfo, job.pagePath, job.params)
 		if err != nil {
-			utils.Logger.Error("Error creating file", zap.String("path", outputPath), zap.Error(err))
 			return err
 		}
-		defer file.Close()
+		initialProps.Data = mergeData(job.props, data)
+	}
 
-		err = pageData.Tmpl.Execute(file, pageData)
-		if err != nil {
-			utils.Logger.Error("Error writing template to file", zap.String("path", outputPath), zap.Error(err))
+	return generatePage(outputDir, job.pagePath, job.routeInfo, initialProps)
+}
+
+// logTimingSummary prints how long each page took, slowest first, followed by
+// the overall build time and how long renders waited for a V8 context.
+func logTimingSummary(timings []pageTiming, total time.Duration, concurrency int) {
+	sorted := make([]pageTiming, len(timings))
+	copy(sorted, timings)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].duration > sorted[j].duration
+	})
+
+	failed := 0
+	for _, timing := range sorted {
+		status := "ok"
+		if timing.err != nil {
+			status = "failed"
+			failed++
+		}
+		utils.Logger.Sugar().Infof("%-8s %10s  %s", status, timing.duration.Round(time.Millisecond), timing.pagePath)
+	}
+
+	poolStats := react.GetPoolStats()
+	utils.Logger.Sugar().Infof(
+		"Rendered %d pages (%d failed) in %s with %d workers, %d renders waited %s for a V8 context",
+		len(timings), failed, total.Round(time.Millisecond), concurrency, poolStats.Waited, poolStats.TotalWait.Round(time.Millisecond),
+	)
+}
+
+// runEntryHandler calls a page's entry handler with a synthetic GET request for
+// the page, marked with router.BUILD_MODE_HEADER and resolved against the
+// configured base URL.
+func runEntryHandler(entryInfo router.EntryRouteInfo, pagePath string, params map[string]string) (map[string]interface{}, error) {
+	baseUrl := utils.GlobalConfig.BaseUrl
+	if baseUrl == "" {
+		baseUrl = "http://localhost:" + strconv.Itoa(utils.GlobalConfig.Port)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(baseUrl, "/")+pagePath, nil)
+	if err != nil {
+		utils.Logger.Error("Error creating entry request", zap.String("route", pagePath), zap.Error(err))
+		return nil, err
+	}
+	req.Header.Set(router.BUILD_MODE_HEADER, "true")
+	req = router.SetRouteParams(req, params)
+
+	recorder := httptest.NewRecorder()
+	data := entryInfo.HandlerFn(recorder, req, entryInfo.FilePath)
+
+	if recorder.Code >= http.StatusBadRequest {
+		err := fmt.Errorf("entry handler for %s responded with status %d", pagePath, recorder.Code)
+		utils.Logger.Error("Error running entry handler", zap.String("route", pagePath), zap.Error(err))
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// mergeData overlays the entry handler data on top of the StaticPaths props.
+func mergeData(props map[string]interface{}, data map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(props)+len(data))
+	for key, value := range props {
+		merged[key] = value
+	}
+	for key, value := range data {
+		merged[key] = value
+	}
+	return merged
+}
+
+// generatePage renders a single page and writes it to the HTML file matching
+// its route inside the output directory.
+func generatePage(outputDir string, route string, routeInfo router.RouteInfo, initialProps react.PageProps) error {
+	pageData, err := react.RenderPage(routeInfo.IsSSG, initialProps, routeInfo.PagePath, routeInfo.Layouts)
+	if err != nil {
+		utils.Logger.Error("Error rendering page", zap.String("route", route), zap.Error(err))
+		return err
+	}
+
+	outputPath := filepath.Join(outputDir, route)
+	if route == "/" || route == "." {
+		outputPath = filepath.Join(outputDir, "index.html")
+	} else {
+		if err := os.MkdirAll(filepath.Dir(outputPath), fs.ModePerm); err != nil {
+			utils.Logger.Error("Error creating directories", zap.String("path", outputPath), zap.Error(err))
 			return err
 		}
+		// Params such as v1.2 look like an extension, so it is always added
+		outputPath += ".html"
+	}
 
-		utils.Logger.Info("Generated static page", zap.String("path", outputPath))
+	file, err := os.Create(outputPath)
+	if err != nil {
+		utils.Logger.Error("Error creating file", zap.String("path", outputPath), zap.Error(err))
+		return err
+	}
+	defer file.Close()
+
+	err = pageData.Tmpl.Execute(file, pageData)
+	if err != nil {
+		utils.Logger.Error("Error writing template to file", zap.String("path", outputPath), zap.Error(err))
+		return err
 	}
 
+	utils.Logger.Info("Generated static page", zap.String("path", outputPath))
 	return nil
 }
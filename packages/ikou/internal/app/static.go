@@ -0,0 +1,126 @@
+package app
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bendigiorgio/ikou/internal/app/react"
+	"github.com/bendigiorgio/ikou/internal/app/router"
+	"github.com/bendigiorgio/ikou/internal/app/utils"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// ServeStaticSite serves the output of `ikou build` from the configured output
+// path behind the middleware chain, optionally mounting the API routes next to
+// it.
+func ServeStaticSite(withApi bool) {
+	intPort := utils.GlobalConfig.Port
+	port := strconv.Itoa(intPort)
+	outputDir := utils.GlobalConfig.OutPath
+
+	serverUrl := "http://localhost:" + port
+	utils.Logger.Sugar().Info("Serving static site on port: ", serverUrl)
+
+	if withApi {
+		router.InitializeApiRouting()
+	} else {
+		router.InitializeMiddleware()
+	}
+
+	r := mux.NewRouter()
+
+	r.HandleFunc("/{route:.*}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		route := path.Clean("/" + vars["route"])
+
+		if withApi && router.HandleApiRoute(w, r, route) {
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		filePath, found := resolveStaticFile(outputDir, route)
+		if !found {
+			utils.Logger.Debug("Static file not found", zap.String("route", route))
+			serveNotFound(w, r, outputDir)
+			return
+		}
+
+		// Client chunks are content hashed so they can be cached forever
+		if strings.HasPrefix(route, react.CLIENT_ASSET_PREFIX) {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+
+		serveFile(w, r, filePath, http.StatusOK)
+	})
+
+	portString := ":" + port
+	utils.Logger.Sugar().Fatal(http.ListenAndServe(portString, router.ApplyMiddleware(r)))
+}
+
+// resolveStaticFile maps a clean URL to a file in the output directory, trying
+// the exact path, then route.html and finally route/index.html.
+func resolveStaticFile(outputDir string, route string) (string, bool) {
+	base := filepath.Join(outputDir, filepath.FromSlash(route))
+
+	candidates := []string{base, base + ".html", filepath.Join(base, "index.html")}
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err == nil && !info.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+func serveNotFound(w http.ResponseWriter, r *http.Request, outputDir string) {
+	notFoundPage := filepath.Join(outputDir, "404.html")
+	if _, err := os.Stat(notFoundPage); err != nil {
+		http.Error(w, "Page not found", http.StatusNotFound)
+		return
+	}
+	serveFile(w, r, notFoundPage, http.StatusNotFound)
+}
+
+// serveFile writes the file with a content type derived from its extension.
+// Successful responses go through http.ServeContent so range and conditional
+// requests keep working.
+func serveFile(w http.ResponseWriter, r *http.Request, filePath string, status int) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		utils.Logger.Error("Error opening static file", zap.String("path", filePath), zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		utils.Logger.Error("Error reading static file", zap.String("path", filePath), zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if status == http.StatusOK {
+		http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+		return
+	}
+
+	if contentType := mime.TypeByExtension(filepath.Ext(filePath)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		io.Copy(w, file)
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"io/fs"
+)
+
+// EMBEDDED_CONFIG is the name of the config file inside the embedded assets.
+const EMBEDDED_CONFIG = "ikou.config.json"
+
+// EMBEDDED_PUBLIC_DIR is the directory the static path is copied to inside the
+// embedded assets.
+const EMBEDDED_PUBLIC_DIR = "public"
+
+// EmbeddedAssets holds the prebuilt frontend of a server built with
+// `ikou build --server`: the config, the public directory, the page bundles and
+// the route manifest. It is nil when the frontend is built from source.
+var EmbeddedAssets fs.FS
+
+// UseEmbeddedAssets makes the server render and serve from assets instead of
+// the source tree. It must be called before any command runs.
+func UseEmbeddedAssets(assets fs.FS) {
+	EmbeddedAssets = assets
+}
+
+// HasEmbeddedAssets reports whether the frontend is served from EmbeddedAssets.
+func HasEmbeddedAssets() bool {
+	return EmbeddedAssets != nil
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"encoding/json"
+	"io"
 	"os"
 
 	"github.com/fsnotify/fsnotify"
@@ -9,12 +10,27 @@ import (
 
 var GlobalConfig IkouConfig
 
+// devMode is set by `ikou dev` to enable the live reload script and the
+// staleness check of cached bundles.
+var devMode bool
+
+// SetDevMode marks the process as the `ikou dev` server.
+func SetDevMode(enabled bool) {
+	devMode = enabled
+}
+
+// IsDevMode reports whether the process was started by `ikou dev`.
+func IsDevMode() bool {
+	return devMode
+}
+
 type IkouConfig struct {
 	BasePath    string `json:"basePath"`
 	OutPath     string `json:"outputPath"`
 	StaticPath  string `json:"staticPath"`
 	UseSrc      bool   `json:"useSrc"`
 	Port        int    `json:"port"`
+	BaseUrl     string `json:"baseUrl"`
 	UseTailwind bool   `json:"useTailwind"`
 	Tailwind    struct {
 		Config  string `json:"config"`
@@ -23,6 +39,14 @@ type IkouConfig struct {
 	} `json:"tailwind"`
 	ApiPath string `json:"apiPath"`
 	LogPath string `json:"logPath"`
+	SSR     struct {
+		PoolSize       int      `json:"poolSize"`
+		Streaming      bool     `json:"streaming"`
+		FetchAllowlist []string `json:"fetchAllowlist"`
+		TimeoutMs      int      `json:"timeoutMs"`
+		MaxHeapMB      int      `json:"maxHeapMb"`
+		FetchMaxBodyMB int      `json:"fetchMaxBodyMb"`
+	} `json:"ssr"`
 }
 
 const BaseJSONConfig = `{
@@ -31,6 +55,7 @@ const BaseJSONConfig = `{
   "staticPath": "frontend/public",
   "useSrc": true,
   "port": 3000,
+  "baseUrl": "http://localhost:3000",
   "useTailwind": true,
   "tailwind": {
     "config": "tailwind.config.js",
@@ -38,19 +63,31 @@ const BaseJSONConfig = `{
     "output": "public/style.css"
   },
   "apiPath": "/api",
-  "logPath": "storage/logs/ikou.log"
+  "logPath": "storage/logs/ikou.log",
+  "ssr": {
+    "poolSize": 4,
+    "streaming": false,
+    "fetchAllowlist": [],
+    "timeoutMs": 5000,
+    "maxHeapMb": 256,
+    "fetchMaxBodyMb": 10
+  }
 }`
 
 func ExtractConfigDetails(configPath string) {
+	var file io.ReadCloser
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		if err := json.Unmarshal([]byte(BaseJSONConfig), &GlobalConfig); err != nil {
-			Logger.Sugar().Fatalf("failed to unmarshal base JSON config: %v", err)
+		// A server binary falls back to the config it was built with
+		if !HasEmbeddedAssets() {
+			if err := json.Unmarshal([]byte(BaseJSONConfig), &GlobalConfig); err != nil {
+				Logger.Sugar().Fatalf("failed to unmarshal base JSON config: %v", err)
+			}
+			return
 		}
-		return
-	}
-
-	file, err := os.Open(configPath)
-	if err != nil {
+		if file, err = EmbeddedAssets.Open(EMBEDDED_CONFIG); err != nil {
+			Logger.Sugar().Fatalf("failed to open embedded config file: %v", err)
+		}
+	} else if file, err = os.Open(configPath); err != nil {
 		Logger.Sugar().Fatalf("failed to open config file: %v", err)
 	}
 	defer file.Close()
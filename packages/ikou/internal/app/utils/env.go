@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PUBLIC_ENV_PREFIX marks environment variables that are inlined into the
+// client bundle. Every other variable is only visible to server code.
+const PUBLIC_ENV_PREFIX = "IKOU_PUBLIC_"
+
+const (
+	NODE_ENV_DEVELOPMENT = "development"
+	NODE_ENV_PRODUCTION  = "production"
+)
+
+// NodeEnv is the NODE_ENV pages are built and rendered with.
+var NodeEnv = NODE_ENV_PRODUCTION
+
+// Env holds the variables loaded by LoadEnv, including NODE_ENV.
+var Env = map[string]string{"NODE_ENV": NODE_ENV_PRODUCTION}
+
+// LoadEnv sets NodeEnv and loads the .env files in dir, later files overriding
+// earlier ones:
+//
+//   - .env
+//   - .env.<nodeEnv>, e.g. .env.production
+//   - .env.local
+//   - .env.<nodeEnv>.local
+//
+// Variables already set in the process environment take precedence over the
+// files. Loaded variables are also exported to the process so route handlers
+// can read them with os.Getenv.
+func LoadEnv(dir string, nodeEnv string) error {
+	NodeEnv = nodeEnv
+	env := map[string]string{}
+
+	files := []string{".env", ".env." + nodeEnv, ".env.local", ".env." + nodeEnv + ".local"}
+	for _, name := range files {
+		values, err := parseEnvFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for key, value := range values {
+			env[key] = value
+		}
+	}
+
+	for key := range env {
+		if value, exists := os.LookupEnv(key); exists {
+			env[key] = value
+		} else {
+			os.Setenv(key, env[key])
+		}
+	}
+
+	// Public variables can also come from the process environment alone
+	for _, entry := range os.Environ() {
+		key, value, _ := strings.Cut(entry, "=")
+		if strings.HasPrefix(key, PUBLIC_ENV_PREFIX) {
+			env[key] = value
+		}
+	}
+
+	env["NODE_ENV"] = nodeEnv
+	Env = env
+	return nil
+}
+
+// PublicEnv returns the loaded variables that may be exposed to the browser.
+func PublicEnv() map[string]string {
+	public := map[string]string{"NODE_ENV": NodeEnv}
+	for key, value := range Env {
+		if strings.HasPrefix(key, PUBLIC_ENV_PREFIX) {
+			public[key] = value
+		}
+	}
+	return public
+}
+
+// parseEnvFile reads KEY=VALUE lines, ignoring blank lines, comments and an
+// optional `export ` prefix. Values may be single quoted, taken literally, or
+// double quoted, with \n, \t, \" and \\ escapes.
+func parseEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNumber)
+		}
+
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return values, nil
+}
+
+func parseEnvValue(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	switch quote := value[0]; quote {
+	case '\'', '"':
+		end := strings.LastIndexByte(value, quote)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated %c quote", quote)
+		}
+		inner := value[1:end]
+		if quote == '\'' {
+			return inner, nil
+		}
+		return strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(inner), nil
+	}
+
+	// Unquoted values end at an inline comment
+	if index := strings.Index(value, " #"); index >= 0 {
+		value = strings.TrimSpace(value[:index])
+	}
+	return value, nil
+}
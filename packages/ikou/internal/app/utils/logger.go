@@ -3,6 +3,7 @@ package utils
 import (
 	"log"
 	"os"
+	"path/filepath"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -18,11 +19,7 @@ func InitLogger(mode string) {
 	internal_mode = mode
 	logPath := "storage/logs/ikou.log"
 
-	if _, err := os.Stat(logPath); os.IsNotExist(err) {
-		if _, err := os.Create(logPath); err != nil {
-			log.Fatalf("failed to create log file: %v", err)
-		}
-	}
+	createLogFile(logPath)
 
 	config := zap.NewDevelopmentConfig()
 	if mode == "prod" {
@@ -42,11 +39,7 @@ func InitLogger(mode string) {
 }
 
 func UpdateLogPath(newPath string) {
-	if _, err := os.Stat(newPath); os.IsNotExist(err) {
-		if _, err := os.Create(newPath); err != nil {
-			log.Fatalf("failed to create log file: %v", err)
-		}
-	}
+	createLogFile(newPath)
 
 	config := zap.NewDevelopmentConfig()
 	if internal_mode == "prod" {
@@ -63,3 +56,16 @@ func UpdateLogPath(newPath string) {
 	}
 	Logger = newLogger
 }
+
+// createLogFile creates the log file and its directory if they do not exist,
+// e.g. when a server binary is run outside of the project.
+func createLogFile(logPath string) {
+	if _, err := os.Stat(logPath); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+			log.Fatalf("failed to create log directory: %v", err)
+		}
+		if _, err := os.Create(logPath); err != nil {
+			log.Fatalf("failed to create log file: %v", err)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"path/filepath"
+
+	"github.com/bendigiorgio/ikou/internal/app/serverbuild"
 	"github.com/bendigiorgio/ikou/internal/app/ssg"
 	"github.com/bendigiorgio/ikou/internal/app/utils"
 	"github.com/urfave/cli/v2"
@@ -17,12 +20,42 @@ func GetBuildCommand() *cli.Command {
 				Usage:   "Path to the config file",
 				Value:   "ikou.config.json",
 			},
+			&cli.BoolFlag{
+				Name:  "server",
+				Usage: "Build a server binary with the routes compiled in instead of static files",
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "Path of the server binary built with --server",
+				Value:   "ikou-server",
+			},
+			&cli.IntFlag{
+				Name:  "concurrency",
+				Usage: "Number of pages to render in parallel (defaults to the number of CPUs)",
+				Value: 0,
+			},
 		},
 		Action: func(c *cli.Context) error {
 			utils.InitLogger("prod")
 			defer utils.Logger.Sync()
 			utils.ExtractConfigDetails(c.String("config"))
-			ssg.GenerateStaticSite()
+			if err := utils.LoadEnv(filepath.Dir(c.String("config")), utils.NODE_ENV_PRODUCTION); err != nil {
+				utils.Logger.Sugar().Errorf("Failed to load environment: %v", err)
+				return err
+			}
+			if c.Bool("server") {
+				if err := serverbuild.BuildServer(c.String("output")); err != nil {
+					utils.Logger.Sugar().Errorf("Server build failed:\n%v", err)
+					return err
+				}
+				utils.Logger.Sugar().Infof("Server built. Run `%s run` to start it.", c.String("output"))
+				return nil
+			}
+			if err := ssg.GenerateStaticSite(c.Int("concurrency")); err != nil {
+				utils.Logger.Sugar().Errorf("Static site generation failed:\n%v", err)
+				return err
+			}
 			utils.Logger.Sugar().Info("Static site generated. Run the command `ikou serve` to serve the static site.")
 			return nil
 		},
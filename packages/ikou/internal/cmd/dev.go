@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"path/filepath"
+
 	"github.com/bendigiorgio/ikou/internal/app"
 	"github.com/bendigiorgio/ikou/internal/app/react"
 	"github.com/bendigiorgio/ikou/internal/app/utils"
@@ -20,9 +22,14 @@ func GetDevCommand() *cli.Command {
 			},
 		},
 		Action: func(c *cli.Context) error {
+			utils.SetDevMode(true)
 			utils.InitLogger("dev")
 			defer utils.Logger.Sync()
 			utils.ExtractConfigDetails(c.String("config"))
+			if err := utils.LoadEnv(filepath.Dir(c.String("config")), utils.NODE_ENV_DEVELOPMENT); err != nil {
+				utils.Logger.Sugar().Fatalf("Failed to load environment: %v", err)
+				return err
+			}
 			go utils.WatchForConfigChanges(c.String("config"))
 
 			if err := react.BuildCSS(); err != nil {
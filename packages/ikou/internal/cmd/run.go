@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"path/filepath"
+
 	"github.com/bendigiorgio/ikou/internal/app"
 	"github.com/bendigiorgio/ikou/internal/app/react"
 	"github.com/bendigiorgio/ikou/internal/app/utils"
@@ -23,6 +25,10 @@ func GetRunCommand() *cli.Command {
 			utils.InitLogger("prod")
 			defer utils.Logger.Sync()
 			utils.ExtractConfigDetails(c.String("config"))
+			if err := utils.LoadEnv(filepath.Dir(c.String("config")), utils.NODE_ENV_PRODUCTION); err != nil {
+				utils.Logger.Sugar().Fatalf("Failed to load environment: %v", err)
+				return err
+			}
 
 			if err := react.BuildCSS(); err != nil {
 				utils.Logger.Sugar().Fatalf("Failed to build CSS: %v", err)
@@ -33,3 +39,18 @@ func GetRunCommand() *cli.Command {
 		},
 	}
 }
+
+// RunServerBinary is the entry point of a server built with
+// `ikou build --server`. It accepts the flags of `ikou run`, which is also
+// what the binary does when no command is given.
+func RunServerBinary(args []string) error {
+	run := GetRunCommand()
+	app := &cli.App{
+		Name:     filepath.Base(args[0]),
+		Usage:    "Ikou server",
+		Flags:    run.Flags,
+		Action:   run.Action,
+		Commands: []*cli.Command{run},
+	}
+	return app.Run(args)
+}
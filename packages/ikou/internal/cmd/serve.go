@@ -1,13 +1,17 @@
 package cmd
 
 import (
+	"path/filepath"
+
+	"github.com/bendigiorgio/ikou/internal/app"
+	"github.com/bendigiorgio/ikou/internal/app/utils"
 	"github.com/urfave/cli/v2"
 )
 
 func GetServeCommand() *cli.Command {
 	return &cli.Command{
 		Name:  "serve",
-		Usage: "Build Static Files",
+		Usage: "Serve the built static site",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:    "config",
@@ -23,7 +27,15 @@ func GetServeCommand() *cli.Command {
 			},
 		},
 		Action: func(c *cli.Context) error {
-			//TODO: Implement serve command
+			utils.InitLogger("prod")
+			defer utils.Logger.Sync()
+			utils.ExtractConfigDetails(c.String("config"))
+			if err := utils.LoadEnv(filepath.Dir(c.String("config")), utils.NODE_ENV_PRODUCTION); err != nil {
+				utils.Logger.Sugar().Fatalf("Failed to load environment: %v", err)
+				return err
+			}
+
+			app.ServeStaticSite(c.Bool("api"))
 			return nil
 		},
 	}
@@ -25,6 +25,7 @@ func main() {
 			cmd.GetRunCommand(),
 			cmd.GetDevCommand(),
 			cmd.GetBuildCommand(),
+			cmd.GetServeCommand(),
 		},
 	}
 